@@ -0,0 +1,89 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+const (
+	ackHeader = "X-Gateway-Ack"
+	ackParam  = "ack"
+)
+
+var (
+	errInvalidAckFrame = errors.New("invalid frame, expected {\"id\": ..., \"body\": ...}")
+)
+
+// ackFrame is the inbound frame format of the connections asking for
+// delivery acknowledgements, the id is picked by the client
+type ackFrame struct {
+	ID   string          `json:"id"`
+	Body json.RawMessage `json:"body"`
+}
+
+// ackReply reports the delivery of a single message back to the client
+type ackReply struct {
+	Type   string `json:"type"`
+	ID     string `json:"id"`
+	Reason string `json:"reason,omitempty"`
+}
+
+func newAckReply(ref string, err error) *ackReply {
+	if err != nil {
+		return &ackReply{Type: "nack", ID: ref, Reason: err.Error()}
+	}
+	return &ackReply{Type: "ack", ID: ref}
+}
+
+// wantsAcks checks whether the client asked for delivery acknowledgements,
+// either with a header or, for browsers, with a query parameter
+func wantsAcks(req *http.Request) bool {
+	if req == nil {
+		return false
+	}
+	v := req.Header.Get(ackHeader)
+	if len(v) == 0 {
+		v = req.URL.Query().Get(ackParam)
+	}
+	acks, _ := strconv.ParseBool(v)
+	return acks
+}
+
+// parseAckFrame extracts the client message id and the body from the frame,
+// a body holding a JSON string is unquoted while anything else is kept as is
+func parseAckFrame(frame string) (string, string, error) {
+	var f ackFrame
+	if err := json.Unmarshal([]byte(frame), &f); err != nil {
+		return "", "", errInvalidAckFrame
+	}
+	if len(f.ID) == 0 || len(f.Body) == 0 {
+		return f.ID, "", errInvalidAckFrame
+	}
+	return f.ID, rawBody(f.Body), nil
+}
+
+// rawBody unquotes a body holding a JSON string and keeps anything else as is
+func rawBody(raw json.RawMessage) string {
+	var body string
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return string(raw)
+	}
+	return body
+}
@@ -0,0 +1,162 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// closePolicyViolation is the close code of the clients disconnected
+	// by an administrator
+	closePolicyViolation = 1008
+)
+
+// disconnectGrace is how long disconnected clients have to close their side
+var disconnectGrace = 5 * time.Second
+
+// connectionInfo is the state of a connection as reported by the admin API
+type connectionInfo struct {
+	ID           int64     `json:"id"`
+	Device       string    `json:"device,omitempty"`
+	Remote       string    `json:"remote"`
+	Connected    time.Time `json:"connected"`
+	Messages     int64     `json:"messages"`
+	Bytes        int64     `json:"bytes"`
+	Published    int64     `json:"published"`
+	Failed       int64     `json:"failed"`
+	LastActivity time.Time `json:"last_activity"`
+	LastSeen     time.Time `json:"last_seen"`
+}
+
+// info returns the state of the connection
+func (c *handler) info() connectionInfo {
+	published, failed := c.counters()
+	return connectionInfo{
+		ID:           c.id,
+		Device:       c.device,
+		Remote:       c.remote,
+		Connected:    c.since,
+		Messages:     atomic.LoadInt64(&c.received),
+		Bytes:        atomic.LoadInt64(&c.bytes),
+		Published:    published,
+		Failed:       failed,
+		LastActivity: time.Unix(0, atomic.LoadInt64(&c.lastMessage)).UTC(),
+		LastSeen:     time.Unix(0, atomic.LoadInt64(&c.lastSeen)).UTC(),
+	}
+}
+
+// newAdminHandler returns the admin API of the broker's clients,
+// requests must carry the token as a bearer token
+func newAdminHandler(b *broker, token string) http.Handler {
+	r := mux.NewRouter()
+	r.HandleFunc("/admin/connections", func(w http.ResponseWriter, req *http.Request) {
+		device := req.URL.Query().Get("device")
+		infos := []connectionInfo{}
+		for _, c := range b.list() {
+			if len(device) == 0 || c.device == device {
+				infos = append(infos, c.info())
+			}
+		}
+		sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+		writeJSONResponse(w, http.StatusOK, infos)
+	}).Methods("GET")
+
+	r.HandleFunc("/admin/connections/{id}", func(w http.ResponseWriter, req *http.Request) {
+		c := findClient(b, mux.Vars(req)["id"])
+		if c == nil {
+			http.Error(w, "Connection not found", http.StatusNotFound)
+			return
+		}
+		if req.Method == "DELETE" {
+			logger.With("conn", c.id).With("device", c.device).Infof("disconnected by admin")
+			c.disconnect(closePolicyViolation, disconnectGrace)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, c.info())
+	}).Methods("GET", "DELETE")
+
+	r.HandleFunc("/admin/devices/{device}/connections", func(w http.ResponseWriter, req *http.Request) {
+		device := mux.Vars(req)["device"]
+		n := 0
+		for _, c := range b.list() {
+			if c.device == device {
+				c.disconnect(closePolicyViolation, disconnectGrace)
+				n++
+			}
+		}
+		logger.With("device", device).Infof("%d connections disconnected by admin", n)
+		writeJSONResponse(w, http.StatusOK, map[string]int{"disconnected": n})
+	}).Methods("DELETE")
+
+	r.HandleFunc("/admin/usage", func(w http.ResponseWriter, req *http.Request) {
+		key := req.URL.Query().Get("key")
+		usage := []Usage{}
+		for _, u := range rates.usage() {
+			if len(key) == 0 || u.Key == key {
+				usage = append(usage, u)
+			}
+		}
+		writeJSONResponse(w, http.StatusOK, usage)
+	}).Methods("GET")
+
+	r.HandleFunc("/admin/loglevel", showLogLevel).Methods("GET", "PUT", "POST")
+
+	return authorizeAdmin(token, r)
+}
+
+// authorizeAdmin rejects the requests without the bearer token
+func authorizeAdmin(token string, next http.Handler) http.Handler {
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		got := []byte(req.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, expected) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gateway-admin"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+func findClient(b *broker, id string) *handler {
+	n, err := strconv.ParseInt(strings.TrimSpace(id), 10, 64)
+	if err != nil {
+		return nil
+	}
+	for _, c := range b.list() {
+		if c.id == n {
+			return c
+		}
+	}
+	return nil
+}
+
+func writeJSONResponse(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
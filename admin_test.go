@@ -0,0 +1,161 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"code.google.com/p/go.net/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// deviceAuth admits every request as the device of its device parameter
+type deviceAuth struct{}
+
+func (a deviceAuth) Validate(req *http.Request) bool   { return true }
+func (a deviceAuth) DeviceID(req *http.Request) string { return req.URL.Query().Get("device") }
+
+func adminRequest(h http.Handler, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if len(token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+// waitClients waits for the broker to have n clients
+func waitClients(t *testing.T, b *broker, n int) []*handler {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		clients := b.list()
+		if len(clients) == n || time.Now().After(deadline) {
+			assert.Len(t, clients, n)
+			return clients
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAdmin_Connections(t *testing.T) {
+	pub = &ackPublisher{}
+	b := newBroker()
+	b.authVal = deviceAuth{}
+	ts, _ := serveTestBroker(t, b)
+	defer ts.Close()
+	h := newAdminHandler(b, "secret")
+
+	assert.Equal(t, http.StatusUnauthorized, adminRequest(h, "GET", "/admin/connections", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, adminRequest(h, "GET", "/admin/connections", "wrong").Code)
+
+	ws := dialTestServer(t, ts, "/?device=dev1")
+	defer ws.Close()
+	assert.Nil(t, websocket.Message.Send(ws, "hello"))
+	other := dialTestServer(t, ts, "/?device=dev2")
+	defer other.Close()
+	waitClients(t, b, 2)
+
+	var infos []connectionInfo
+	w := adminRequest(h, "GET", "/admin/connections?device=dev1", "secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &infos))
+	if assert.Len(t, infos, 1) {
+		assert.Equal(t, "dev1", infos[0].Device)
+		assert.NotEmpty(t, infos[0].Remote)
+		assert.False(t, infos[0].Connected.IsZero())
+	}
+
+	var info connectionInfo
+	w = adminRequest(h, "GET", "/admin/connections/"+jsonString(infos[0].ID), "secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &info))
+	assert.Equal(t, infos[0].ID, info.ID)
+	assert.Equal(t, http.StatusNotFound, adminRequest(h, "GET", "/admin/connections/0", "secret").Code)
+	assert.Equal(t, http.StatusNotFound, adminRequest(h, "GET", "/admin/connections/x", "secret").Code)
+}
+
+func TestAdmin_Disconnect(t *testing.T) {
+	pub = &ackPublisher{}
+	b := newBroker()
+	b.authVal = deviceAuth{}
+	ts, _ := serveTestBroker(t, b)
+	defer ts.Close()
+	h := newAdminHandler(b, "secret")
+
+	var conns []*websocket.Conn
+	for _, device := range []string{"dev1", "dev1", "dev2"} {
+		ws := dialTestServer(t, ts, "/?device="+device)
+		defer ws.Close()
+		conns = append(conns, ws)
+	}
+	clients := waitClients(t, b, 3)
+
+	w := adminRequest(h, "DELETE", "/admin/devices/dev1/connections", "secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "{\"disconnected\":2}\n", w.Body.String())
+	for _, ws := range conns[:2] {
+		ws.SetDeadline(time.Now().Add(5 * time.Second))
+		var m string
+		assert.Equal(t, io.EOF, websocket.Message.Receive(ws, &m), "Clients of the device must be closed")
+		ws.Close()
+	}
+	clients = waitClients(t, b, 1)
+
+	w = adminRequest(h, "DELETE", "/admin/connections/"+jsonString(clients[0].id), "secret")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	var m string
+	assert.Equal(t, io.EOF, websocket.Message.Receive(conns[2], &m))
+	conns[2].Close()
+	waitClients(t, b, 0)
+}
+
+func TestHandler_Disconnect(t *testing.T) {
+	pub = &ackPublisher{}
+	ts, b := newTestServer(t)
+	defer ts.Close()
+
+	ws := dialTestServer(t, ts, "/")
+	defer ws.Close()
+	clients := waitClients(t, b, 1)
+
+	// the client never closes its side
+	clients[0].disconnect(closePolicyViolation, 50*time.Millisecond)
+	waitClients(t, b, 0)
+}
+
+func jsonString(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func TestAdmin_LogLevel(t *testing.T) {
+	_, restore := captureLogs(false)
+	defer restore()
+	h := newAdminHandler(newBroker(), "secret")
+
+	assert.Equal(t, http.StatusUnauthorized, adminRequest(h, "PUT", "/admin/loglevel?level=debug", "").Code)
+	assert.False(t, logger.Enabled(levelDebug), "Only administrators may change the log level")
+
+	w := adminRequest(h, "PUT", "/admin/loglevel?level=debug", "secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, logger.Enabled(levelDebug))
+}
@@ -0,0 +1,174 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/eapache/go-resiliency/breaker"
+)
+
+// Actions signalling the clients to back off while the breaker is open
+const (
+	breakerThrottle = "throttle"
+	breakerClose    = "close"
+)
+
+const (
+	defaultBreakerErrors    = 10
+	defaultBreakerSuccesses = 1
+	defaultBreakerTimeout   = 10000
+
+	// closeTryAgainLater is the WebSocket close code asking to reconnect later
+	closeTryAgainLater = 1013
+)
+
+var (
+	breakerRejected = newCounter("gateway_breaker_rejected_total", "Number of messages rejected while the breaker was open.")
+	breakerSlow     = newCounter("gateway_breaker_slow_total", "Number of deliveries slower than the breaker latency threshold.")
+
+	errBackendSlow = errors.New("backend is too slow")
+)
+
+// BreakerPublisher wraps another publisher with a circuit breaker, once the
+// backend failed or was too slow too often the messages are rejected right
+// away until the backend had the time to recover
+type BreakerPublisher struct {
+	inner   Publisher
+	breaker *breaker.Breaker
+	latency time.Duration
+}
+
+// NewBreakerPublisher creates a new circuit breaker around the publisher
+func NewBreakerPublisher(inner Publisher) Publisher {
+	return &BreakerPublisher{inner: inner}
+}
+
+// Config configures the wrapped publisher and the breaker
+func (p *BreakerPublisher) Config(clientID string, args *PubConfig) {
+	p.inner.Config(clientID, args)
+
+	conf := args.Breaker
+	p.breaker = breaker.New(conf.Errors, conf.Successes,
+		time.Duration(conf.Timeout)*time.Millisecond)
+	p.latency = time.Duration(conf.Latency) * time.Millisecond
+}
+
+// Start fires a publisher listener, the accepted messages are passed on
+// to a listener of the wrapped publisher
+func (p *BreakerPublisher) Start(in <-chan *Message) {
+	out := make(chan *Message)
+	defer close(out)
+	go p.inner.Start(out)
+
+	for msg := range in {
+		start := time.Now()
+		result := make(chan error, 1)
+		delivered := msg.delivered
+		msg.delivered = func(err error) {
+			result <- err
+			if delivered != nil {
+				delivered(err)
+			}
+		}
+
+		// the breaker learns the outcome once the backend reports it
+		err := p.breaker.Go(func() error {
+			return p.await(result, start)
+		})
+		if err != nil {
+			breakerRejected.with().inc()
+			logger.Message(msg).Debugf("Breaker rejected msg: %v", err)
+			msg.delivered = delivered
+			msg.Delivered(err)
+			continue
+		}
+		out <- msg
+	}
+}
+
+// Close closes the wrapped publisher
+func (p *BreakerPublisher) Close() error {
+	return closePublisher(p.inner)
+}
+
+// health reports the state of the wrapped publisher
+func (p *BreakerPublisher) health(status map[string]error) {
+	checkHealth(p.inner, status)
+}
+
+// await waits for the delivery result, deliveries taking longer than
+// the latency threshold count as failures
+func (p *BreakerPublisher) await(result <-chan error, start time.Time) error {
+	if p.latency <= 0 {
+		return <-result
+	}
+	timer := time.NewTimer(p.latency - time.Since(start))
+	defer timer.Stop()
+	select {
+	case err := <-result:
+		return err
+	case <-timer.C:
+		breakerSlow.with().inc()
+		return errBackendSlow
+	}
+}
+
+// throttleReply tells the client to stop sending for retry_after seconds
+type throttleReply struct {
+	Type       string `json:"type"`
+	RetryAfter int    `json:"retry_after"`
+}
+
+// retryAfter returns how many seconds the clients should back off for,
+// which is the time the breaker stays open
+func retryAfter() time.Duration {
+	timeout := time.Duration(args.Pub.Breaker.Timeout) * time.Millisecond
+	return (timeout + time.Second - 1) / time.Second * time.Second
+}
+
+// backoff signals the client to back off when the breaker rejected one
+// of its messages, at most once per retry period
+func (c *handler) backoff(msg *Message, err error) {
+	if err != breaker.ErrBreakerOpen {
+		return
+	}
+	if c.throttle(retryAfter()) && args.Pub.Breaker.Action == breakerClose {
+		c.close(closeTryAgainLater)
+	}
+}
+
+// throttle tells the client to back off for retry, rounded up to seconds,
+// at most once per retry period, it tells whether the client was told
+func (c *handler) throttle(retry time.Duration) bool {
+	retry = (retry + time.Second - 1) / time.Second * time.Second
+	now := time.Now().UnixNano()
+	until := atomic.LoadInt64(&c.throttled)
+	if now < until || !atomic.CompareAndSwapInt64(&c.throttled, until, now+int64(retry)) {
+		return false
+	}
+
+	select {
+	case <-c.doneCh:
+		return false
+	default:
+	}
+	var reply interface{} = &throttleReply{Type: "throttle", RetryAfter: int(retry / time.Second)}
+	c.write(&reply)
+	return true
+}
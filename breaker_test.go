@@ -0,0 +1,98 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eapache/go-resiliency/breaker"
+	"github.com/stretchr/testify/assert"
+)
+
+// publishOne sends the message with the body and waits for its result
+func publishOne(in chan<- *Message, body string) error {
+	result := make(chan error, 1)
+	msg := NewMessage(body)
+	msg.delivered = func(err error) { result <- err }
+	in <- msg
+	return <-result
+}
+
+func TestBreakerPublisher_Errors(t *testing.T) {
+	p := NewBreakerPublisher(&ackPublisher{})
+	p.Config("test", &PubConfig{Breaker: BreakerConfig{Errors: 2, Successes: 1, Timeout: 100}})
+	in := make(chan *Message)
+	defer close(in)
+	go p.Start(in)
+
+	assert.Nil(t, publishOne(in, "ok"))
+	assert.NotNil(t, publishOne(in, "fail"))
+	assert.NotNil(t, publishOne(in, "fail"))
+
+	// the breaker learns the results asynchronously
+	var err error
+	for i := 0; i < 100; i++ {
+		if err = publishOne(in, "ok"); err != nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, breaker.ErrBreakerOpen, err, "Breaker must open after too many errors")
+
+	time.Sleep(150 * time.Millisecond)
+	assert.Nil(t, publishOne(in, "ok"), "Breaker must let messages through after the timeout")
+}
+
+func TestBreakerPublisher_Latency(t *testing.T) {
+	stalled := &chanPublisher{out: make(chan *Message, 100)}
+	p := NewBreakerPublisher(stalled)
+	p.Config("test", &PubConfig{Breaker: BreakerConfig{Errors: 1, Successes: 1, Timeout: 1000, Latency: 10}})
+	in := make(chan *Message)
+	defer close(in)
+	go p.Start(in)
+
+	rejected := make(chan error, 100)
+	for i := 0; i < 100 && len(rejected) == 0; i++ {
+		msg := NewMessage("never delivered")
+		msg.delivered = func(err error) { rejected <- err }
+		in <- msg
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case err := <-rejected:
+		assert.Equal(t, breaker.ErrBreakerOpen, err, "Slow deliveries must count as errors")
+	case <-time.After(time.Second):
+		t.Fatal("Breaker did not open")
+	}
+}
+
+func TestHandler_Backoff(t *testing.T) {
+	defer func(b BreakerConfig) { args.Pub.Breaker = b }(args.Pub.Breaker)
+	args.Pub.Breaker.Timeout = 1500
+	args.Pub.Breaker.Action = breakerClose
+
+	h := &handler{ch: make(chan *interface{}, 10), doneCh: make(chan bool)}
+	h.backoff(NewMessage("one"), errBackendSlow)
+	assert.Len(t, h.ch, 0, "Only rejected messages must throttle the client")
+
+	h.backoff(NewMessage("two"), breaker.ErrBreakerOpen)
+	h.backoff(NewMessage("three"), breaker.ErrBreakerOpen)
+	if assert.Len(t, h.ch, 2, "Client must be throttled once per retry period") {
+		assert.Equal(t, &throttleReply{Type: "throttle", RetryAfter: 2}, *<-h.ch)
+		assert.Equal(t, closeFrame(closeTryAgainLater), *<-h.ch)
+	}
+}
@@ -17,33 +17,71 @@ package main
 
 import (
 	"errors"
-	"log"
 	"net/http"
 
 	"code.google.com/p/go.net/websocket"
 )
 
+var errInvalidToken = errors.New("Invalid token")
+
 type Authenticator interface {
 	Validate(*http.Request) bool
 }
 
+// DeviceIdentifier is implemented by the authenticators which can tell
+// the device a validated request was sent by
+type DeviceIdentifier interface {
+	DeviceID(*http.Request) string
+}
+
+// TenantIdentifier is implemented by the authenticators which can tell
+// the tenant of the device a validated request was sent by
+type TenantIdentifier interface {
+	TenantID(*http.Request) string
+}
+
+// authFailureReason tells whether the rejected request had credentials at all
+func authFailureReason(req *http.Request) string {
+	if len(req.Header.Get("Authorization")) == 0 && len(req.URL.Query().Get("access_token")) == 0 {
+		return "missing_credentials"
+	}
+	return "invalid_credentials"
+}
+
+// deviceID returns the id of the device which sent the request, if known
+func deviceID(a Authenticator, req *http.Request) string {
+	if d, ok := a.(DeviceIdentifier); ok {
+		return d.DeviceID(req)
+	}
+	return ""
+}
+
+// tenantID returns the tenant of the device which sent the request, if known
+func tenantID(a Authenticator, req *http.Request) string {
+	if t, ok := a.(TenantIdentifier); ok {
+		return t.TenantID(req)
+	}
+	return ""
+}
+
 func newBroker() *broker {
 	clients := make(map[int64]*handler, 5)
 	addCh := make(chan *handler, 5)
 	delCh := make(chan *handler)
 	doneCh := make(chan bool)
 	errCh := make(chan error)
+	listCh := make(chan chan []*handler)
 	var authV Authenticator
 
 	switch args.Server.AuthMethod {
 	case "none":
-		log.Println("Using no authentication")
+		logger.Infof("Using no authentication")
 		authV = NewNoAuth()
 	case "simple":
-		log.Println("Using simple authentication")
+		logger.Infof("Using simple authentication")
 		authV = NewSimpleAuth(args.Server.Token)
 	case "jwt":
-		log.Println("Using JWT authentication")
+		logger.Infof("Using JWT authentication")
 		authV = NewJwtAuth()
 	}
 
@@ -53,7 +91,11 @@ func newBroker() *broker {
 		delCh,
 		doneCh,
 		errCh,
+		listCh,
 		authV,
+		newConnLimits(args.Server.MaxConnections, args.Server.MaxDeviceConnections, args.Server.MaxIPConnections),
+		int64(args.Server.MaxMessageKB) * 1024,
+		newPipeline(pub, &args.Server.Pipeline),
 	}
 }
 
@@ -63,59 +105,126 @@ type broker struct {
 	delCh   chan *handler
 	doneCh  chan bool
 	errCh   chan error
+	listCh  chan chan []*handler
 	authVal Authenticator
+
+	// limits of the connections and of the size of their messages
+	limits     *connLimits
+	maxMessage int64
+
+	// pipeline hands the messages of the clients to the publisher
+	pipeline *pipeline
 }
 
 func (s *broker) add(c *handler) { s.addCh <- c }
 func (s *broker) del(c *handler) { s.delCh <- c }
 func (s *broker) err(err error)  { s.errCh <- err }
-func (s *broker) listen() {
 
-	onConnected := func(ws *websocket.Conn) {
+// list returns the clients connected at the moment
+func (s *broker) list() []*handler {
+	ch := make(chan []*handler)
+	s.listCh <- ch
+	return <-ch
+}
 
-		// make sure closes cleanly
-		defer func() {
-			err := ws.Close()
-			if err != nil {
-				s.errCh <- err
-			}
-		}()
-
-		// create a new producer client per connection
-		if s.authVal.Validate(ws.Request()) {
-			handler := newClient(ws, s)
-			s.add(handler)
-			handler.listen()
-		} else {
-			log.Println("Invalid token")
-			s.errCh <- errors.New("Invalid token")
+// onConnected serves the upgraded connection until it ends, valid tells
+// whether the request was authenticated
+func (s *broker) onConnected(ws *websocket.Conn, valid bool) {
+	// make sure closes cleanly
+	defer func() {
+		err := ws.Close()
+		if err != nil {
+			s.errCh <- err
 		}
+	}()
+
+	// create a new producer client per connection
+	connect := spanFromContext(ws.Request().Context())
+	if valid {
+		connect.End(nil)
+		handler := newClient(ws, s)
+		s.add(handler)
+		presence.connected(handler)
+		handler.listen()
+	} else {
+		connect.End(errInvalidToken)
+		logger.With("remote", ws.Request().RemoteAddr).Warnf("Invalid token")
+		reason := authFailureReason(ws.Request())
+		authFailures.with(args.Server.AuthMethod, reason).inc()
+		presence.authFailed(ws.Request(), deviceID(s.authVal, ws.Request()), reason)
+		s.errCh <- errInvalidToken
+	}
+}
+
+// onRequest authenticates and upgrades the request unless the connection
+// limits are hit
+func (s *broker) onRequest(w http.ResponseWriter, req *http.Request) {
+	// the connect span covers the upgrade and the authentication,
+	// it is ended here too when the upgrade fails
+	span := startSpan("gateway.connect", spanServer, remoteSpan(req.Header.Get(headerTraceparent)))
+	span.SetAttr("http.target", req.URL.Path)
+	span.SetAttr("net.peer.name", req.RemoteAddr)
+	defer span.End(nil)
 
+	ip := remoteIP(req, args.Server.TrustedProxies)
+	if limit := s.limits.acquire(ip); len(limit) > 0 {
+		connectionsRejected.with(limit).inc()
+		logger.With("remote", ip).Warnf("Connection rejected, %s limit reached", limit)
+		span.SetAttr("gateway.rejected", limit)
+		span.End(errTooManyConnections)
+		http.Error(w, errTooManyConnections.Error(), http.StatusServiceUnavailable)
+		return
 	}
+	defer s.limits.release(ip)
 
-	onRequest := func(w http.ResponseWriter, req *http.Request) {
-		s := websocket.Server{
-			Handler: websocket.Handler(onConnected),
+	// the device is authenticated before the upgrade to be limited as well
+	connectionAttempts.with(args.Server.AuthMethod).inc()
+	auth := startSpan("gateway.auth", spanInternal, span)
+	auth.SetAttr("gateway.auth.method", args.Server.AuthMethod)
+	valid := s.authVal.Validate(req.WithContext(contextWithSpan(req.Context(), auth)))
+	if valid {
+		auth.End(nil)
+		device := deviceID(s.authVal, req)
+		if !s.limits.acquireDevice(device) {
+			connectionsRejected.with(limitDevice).inc()
+			logger.With("remote", ip).With("device", device).Warnf("Connection rejected, %s limit reached", limitDevice)
+			span.SetAttr("gateway.rejected", limitDevice)
+			span.End(errTooManyConnections)
+			http.Error(w, errTooManyConnections.Error(), http.StatusServiceUnavailable)
+			return
 		}
-		s.ServeHTTP(w, req)
+		defer s.limits.releaseDevice(device)
+	} else {
+		auth.End(errInvalidToken)
 	}
 
-	http.HandleFunc(args.Server.Root, onRequest)
+	server := websocket.Server{
+		Handler: func(ws *websocket.Conn) { s.onConnected(ws, valid) },
+	}
+	server.ServeHTTP(frameLimitWriter{w, s.maxMessage}, req.WithContext(contextWithSpan(req.Context(), span)))
+}
+
+func (s *broker) listen() {
+	http.HandleFunc(args.Server.Root, s.onRequest)
 
 	for {
 		select {
 		case c := <-s.addCh:
 			s.clients[c.id] = c
-			if args.Trace {
-				log.Printf("app:%d handler:%d clients:%d", args.Index, c.id, len(s.clients))
-			}
+			connectionsActive.with().set(float64(len(s.clients)))
+			c.log.Debugf("handler added, clients:%d", len(s.clients))
 		case c := <-s.delCh:
 			delete(s.clients, c.id)
-			if args.Trace {
-				Trace("handler deleted", c.id)
-			}
+			connectionsActive.with().set(float64(len(s.clients)))
+			c.log.Debugf("handler deleted, clients:%d", len(s.clients))
 		case err := <-s.errCh:
-			log.Println("error:", err.Error())
+			logger.Errorf("error: %v", err)
+		case ch := <-s.listCh:
+			clients := make([]*handler, 0, len(s.clients))
+			for _, c := range s.clients {
+				clients = append(clients, c)
+			}
+			ch <- clients
 		}
 	}
 }
@@ -35,6 +35,9 @@ func init() {
 	loadConfig("./defaults.json", &args)
 
 	args.Trace = GetEnvVarAsBool("GATEWAY_TRACE", args.Trace)
+	configLogging(&args.Log, args.Trace)
+	configTracing(&args.Tracing)
+
 	args.Pub.Ack = GetEnvVarAsBool("GATEWAY_ACKS", args.Pub.Ack)
 	args.Pub.Compress = GetEnvVarAsBool("GATEWAY_COMPRESS", args.Pub.Compress)
 
@@ -58,6 +61,109 @@ func init() {
 		log.Panicf("Invalid gateway authentication method: %v", args.Server.AuthMethod)
 	}
 
+	args.Server.ShutdownTimeout = GetEnvVarAsInt("GATEWAY_SHUTDOWN_TIMEOUT", args.Server.ShutdownTimeout)
+	if args.Server.ShutdownTimeout <= 0 {
+		args.Server.ShutdownTimeout = defaultShutdownTimeout
+	}
+
+	args.Server.PingInterval = GetEnvVarAsInt("GATEWAY_PING_INTERVAL", args.Server.PingInterval)
+	args.Server.ReadTimeout = GetEnvVarAsInt("GATEWAY_READ_TIMEOUT", args.Server.ReadTimeout)
+	args.Server.IdleTimeout = GetEnvVarAsInt("GATEWAY_IDLE_TIMEOUT", args.Server.IdleTimeout)
+	if args.Server.PingInterval <= 0 {
+		args.Server.PingInterval = defaultPingInterval
+	}
+	if args.Server.ReadTimeout <= 0 {
+		args.Server.ReadTimeout = defaultReadTimeout
+	}
+	if args.Server.ReadTimeout <= args.Server.PingInterval {
+		log.Panicf("Read timeout must be longer than the ping interval")
+	}
+
+	args.Server.MaxMessageKB = GetEnvVarAsInt("GATEWAY_MAX_MESSAGE_KB", args.Server.MaxMessageKB)
+	if args.Server.MaxMessageKB <= 0 {
+		args.Server.MaxMessageKB = defaultMaxMessageKB
+	}
+	configConnLimits(&args.Server)
+
+	SetWithStringEnvVar("GATEWAY_TENANT_CLAIM", &args.Server.TenantClaim)
+	if len(args.Server.TenantClaim) == 0 {
+		args.Server.TenantClaim = defaultTenantClaim
+	}
+	configRateLimit(&args.Server.RateLimit)
+	configPipeline(&args.Server.Pipeline)
+	configIngest(&args.Server.Ingest)
+
+	SetWithStringEnvVar("GATEWAY_BACKEND", &args.Pub.Backend)
+	args.Pub.Backend = strings.ToLower(args.Pub.Backend)
+
+	if len(args.Pub.Backend) == 0 {
+		args.Pub.Backend = "kafka"
+	}
+
+	if args.Pub.Backend == "fanout" {
+		if fanout := os.Getenv("GATEWAY_FANOUT"); len(fanout) > 0 {
+			args.Pub.Fanout = parseFanoutTargets(fanout)
+		}
+		if len(args.Pub.Fanout) == 0 {
+			log.Panicf("Fan-out publisher requires at least one backend")
+		}
+		seen := make(map[string]bool)
+		for i := range args.Pub.Fanout {
+			t := &args.Pub.Fanout[i]
+			t.Backend = strings.ToLower(t.Backend)
+			if seen[t.Backend] {
+				log.Panicf("Duplicate fan-out backend: %v", t.Backend)
+			}
+			seen[t.Backend] = true
+			t.Policy = strings.ToLower(t.Policy)
+			switch t.Policy {
+			case "":
+				t.Policy = fanoutRequired
+			case fanoutRequired, fanoutBestEffort:
+			default:
+				log.Panicf("Invalid fan-out policy for %s: %v", t.Backend, t.Policy)
+			}
+			configBackend(t.Backend)
+		}
+	} else {
+		configBackend(args.Pub.Backend)
+	}
+
+	args.Pub.Spool.Enabled = GetEnvVarAsBool("GATEWAY_SPOOL", args.Pub.Spool.Enabled)
+	SetWithStringEnvVar("GATEWAY_SPOOL_DIR", &args.Pub.Spool.Dir)
+	args.Pub.Spool.MaxSizeMB = GetEnvVarAsInt("GATEWAY_SPOOL_MAX_SIZE_MB", args.Pub.Spool.MaxSizeMB)
+
+	args.Pub.DeadLetter.Enabled = GetEnvVarAsBool("GATEWAY_DEAD_LETTER", args.Pub.DeadLetter.Enabled)
+	SetWithStringEnvVar("GATEWAY_DEAD_LETTER_TOPIC", &args.Pub.DeadLetter.Topic)
+	SetWithStringEnvVar("GATEWAY_DEAD_LETTER_DIR", &args.Pub.DeadLetter.Dir)
+
+	configBreaker(&args.Pub.Breaker)
+
+	args.Pub.Downlink.Enabled = GetEnvVarAsBool("GATEWAY_DOWNLINK", args.Pub.Downlink.Enabled)
+	SetWithStringEnvVar("GATEWAY_DOWNLINK_TOPIC", &args.Pub.Downlink.Topic)
+	SetWithStringEnvVar("GATEWAY_DOWNLINK_GROUP", &args.Pub.Downlink.Group)
+	SetWithStringEnvVar("GATEWAY_DOWNLINK_UNDELIVERABLE_TOPIC", &args.Pub.Downlink.UndeliverableTopic)
+	if args.Pub.Downlink.Enabled && len(args.Pub.Downlink.Topic) == 0 {
+		log.Panicf("Downlink requires a command topic")
+	}
+
+	args.Pub.Presence.Enabled = GetEnvVarAsBool("GATEWAY_PRESENCE", args.Pub.Presence.Enabled)
+	SetWithStringEnvVar("GATEWAY_PRESENCE_TOPIC", &args.Pub.Presence.Topic)
+	if args.Pub.Presence.Enabled && len(args.Pub.Presence.Topic) == 0 {
+		log.Panicf("Presence events require a topic")
+	}
+
+	args.Pub.Will.Enabled = GetEnvVarAsBool("GATEWAY_WILL", args.Pub.Will.Enabled)
+	SetWithStringEnvVar("GATEWAY_WILL_TOPIC", &args.Pub.Will.Topic)
+	args.Pub.Will.MaxBytes = GetEnvVarAsInt("GATEWAY_WILL_MAX_BYTES", args.Pub.Will.MaxBytes)
+	if args.Pub.Will.MaxBytes <= 0 {
+		args.Pub.Will.MaxBytes = defaultWillMaxBytes
+	}
+	if args.Pub.Will.Enabled && len(args.Pub.Will.Topic) == 0 {
+		log.Panicf("Last wills require a topic")
+	}
+	configAdmin(&args.Admin)
+
 	SetWithStringEnvVar("GATEWAY_TOPIC", &args.Pub.Topic)
 
 	var kafkaNodes string = os.Getenv("GATEWAY_QUEUE")
@@ -76,16 +182,273 @@ func init() {
 		kafka, _ := cf.Services.WithTag("kafka")
 		if len(kafka) > 0 {
 			kafkaNodes = kafka[0].Credentials["uri"].(string)
+			configKafkaCredentials(kafka[0].Credentials, &args.Pub)
+		}
+
+		redis, _ := cf.Services.WithTag("redis")
+		if len(redis) > 0 {
+			creds := redis[0].Credentials
+			if host, ok := creds["host"].(string); ok {
+				args.Pub.Redis.Address = fmt.Sprintf("%s:%v", host, creds["port"])
+			}
+			if password, ok := creds["password"].(string); ok {
+				args.Pub.Redis.Password = password
+			}
 		}
 	} else {
-		log.Println("No CF")
+		logger.Infof("No CF")
 	}
 
 	if len(kafkaNodes) > 0 {
 		args.Pub.URI = strings.Split(kafkaNodes, ",")
 	}
 
-	Trace("config", args)
+	args.Pub.TLS.Enabled = GetEnvVarAsBool("GATEWAY_QUEUE_TLS", args.Pub.TLS.Enabled)
+	SetWithStringEnvVar("GATEWAY_QUEUE_TLS_CA", &args.Pub.TLS.CA)
+	SetWithStringEnvVar("GATEWAY_QUEUE_TLS_CERT", &args.Pub.TLS.Cert)
+	SetWithStringEnvVar("GATEWAY_QUEUE_TLS_KEY", &args.Pub.TLS.Key)
+	SetWithStringEnvVar("GATEWAY_QUEUE_TLS_SERVER_NAME", &args.Pub.TLS.ServerName)
+	SetWithStringEnvVar("GATEWAY_QUEUE_SASL_MECHANISM", &args.Pub.SASL.Mechanism)
+	SetWithStringEnvVar("GATEWAY_QUEUE_SASL_USER", &args.Pub.SASL.User)
+	SetWithStringEnvVar("GATEWAY_QUEUE_SASL_PASSWORD", &args.Pub.SASL.Password)
+
+	if err := validateKafkaAuth(&args.Pub); err != nil {
+		log.Panicf("Invalid Kafka authentication: %v", err)
+	}
+
+	logger = logger.With("gateway", args.ID)
+	Trace("config", args.redacted())
+}
+
+// configBackend applies the env variable overrides for the named backend
+func configBackend(backend string) {
+	switch backend {
+	case "kafka":
+		prod := &args.Pub.Producer
+		SetWithStringEnvVar("GATEWAY_QUEUE_REQUIRED_ACKS", &prod.Acks)
+		SetWithStringEnvVar("GATEWAY_QUEUE_COMPRESSION", &prod.Compression)
+		SetWithStringEnvVar("GATEWAY_QUEUE_PARTITIONER", &prod.Partitioner)
+		SetWithStringEnvVar("GATEWAY_QUEUE_VERSION", &prod.Version)
+		prod.Idempotent = GetEnvVarAsBool("GATEWAY_QUEUE_IDEMPOTENT", prod.Idempotent)
+		prod.Acks = strings.ToLower(prod.Acks)
+		prod.Compression = strings.ToLower(prod.Compression)
+		prod.Partitioner = strings.ToLower(prod.Partitioner)
+	case "file":
+		SetWithStringEnvVar("GATEWAY_FILE_DIR", &args.Pub.File.Dir)
+		SetWithStringEnvVar("GATEWAY_FILE_FSYNC", &args.Pub.File.Fsync)
+		args.Pub.File.Gzip = GetEnvVarAsBool("GATEWAY_FILE_GZIP", args.Pub.File.Gzip)
+		args.Pub.File.Fsync = strings.ToLower(args.Pub.File.Fsync)
+		switch args.Pub.File.Fsync {
+		case "", "always", "interval", "never":
+		default:
+			log.Panicf("Invalid file publisher fsync policy: %v", args.Pub.File.Fsync)
+		}
+	case "redis":
+		SetWithStringEnvVar("GATEWAY_REDIS_ADDRESS", &args.Pub.Redis.Address)
+		SetWithStringEnvVar("GATEWAY_REDIS_PASSWORD", &args.Pub.Redis.Password)
+		SetWithStringEnvVar("GATEWAY_REDIS_STREAM", &args.Pub.Redis.Stream)
+		args.Pub.Redis.MaxLen = int(GetEnvVarAsInt64("GATEWAY_REDIS_MAXLEN", int64(args.Pub.Redis.MaxLen)))
+	default:
+		log.Panicf("Invalid gateway publisher backend: %v", backend)
+	}
+}
+
+// configConnLimits applies the environment to the connection limits
+func configConnLimits(s *ServerConfig) {
+	s.MaxConnections = int(GetEnvVarAsInt64("GATEWAY_MAX_CONNECTIONS", int64(s.MaxConnections)))
+	s.MaxDeviceConnections = GetEnvVarAsInt("GATEWAY_MAX_CONNECTIONS_PER_DEVICE", s.MaxDeviceConnections)
+	s.MaxIPConnections = GetEnvVarAsInt("GATEWAY_MAX_CONNECTIONS_PER_IP", s.MaxIPConnections)
+	s.TrustedProxies = GetEnvVarAsInt("GATEWAY_TRUSTED_PROXIES", s.TrustedProxies)
+}
+
+// configBreaker applies the environment and the defaults to the circuit breaker
+func configBreaker(b *BreakerConfig) {
+	b.Enabled = GetEnvVarAsBool("GATEWAY_BREAKER", b.Enabled)
+	b.Errors = GetEnvVarAsInt("GATEWAY_BREAKER_ERRORS", b.Errors)
+	b.Timeout = int(GetEnvVarAsInt64("GATEWAY_BREAKER_TIMEOUT_MS", int64(b.Timeout)))
+	b.Latency = GetEnvVarAsInt("GATEWAY_BREAKER_LATENCY_MS", b.Latency)
+	SetWithStringEnvVar("GATEWAY_BREAKER_ACTION", &b.Action)
+	b.Action = strings.ToLower(b.Action)
+
+	if b.Errors <= 0 {
+		b.Errors = defaultBreakerErrors
+	}
+	if b.Successes <= 0 {
+		b.Successes = defaultBreakerSuccesses
+	}
+	if b.Timeout <= 0 {
+		b.Timeout = defaultBreakerTimeout
+	}
+	switch b.Action {
+	case "":
+		b.Action = breakerThrottle
+	case breakerThrottle, breakerClose:
+	default:
+		log.Panicf("Invalid circuit breaker action: %v", b.Action)
+	}
+}
+
+// configRateLimit applies the environment and the defaults to the rate limits
+func configRateLimit(r *RateLimitConfig) {
+	r.Enabled = GetEnvVarAsBool("GATEWAY_RATE_LIMIT", r.Enabled)
+	r.Messages = GetEnvVarAsInt("GATEWAY_RATE_LIMIT_MESSAGES", r.Messages)
+	r.KB = GetEnvVarAsInt("GATEWAY_RATE_LIMIT_KB", r.KB)
+	r.DeviceMessages = GetEnvVarAsInt("GATEWAY_RATE_LIMIT_DEVICE_MESSAGES", r.DeviceMessages)
+	r.DeviceKB = GetEnvVarAsInt("GATEWAY_RATE_LIMIT_DEVICE_KB", r.DeviceKB)
+	SetWithStringEnvVar("GATEWAY_RATE_LIMIT_ACTION", &r.Action)
+	r.Action = strings.ToLower(r.Action)
+	r.DailyMessages = GetEnvVarAsInt64("GATEWAY_QUOTA_DAILY_MESSAGES", r.DailyMessages)
+	r.DailyMB = GetEnvVarAsInt64("GATEWAY_QUOTA_DAILY_MB", r.DailyMB)
+	SetWithStringEnvVar("GATEWAY_QUOTA_BY", &r.QuotaBy)
+	r.QuotaBy = strings.ToLower(r.QuotaBy)
+	SetWithStringEnvVar("GATEWAY_QUOTA_USAGE_FILE", &r.UsageFile)
+
+	switch r.Action {
+	case "":
+		r.Action = rateDrop
+	case rateDrop, rateDelay, rateThrottle, rateDisconnect:
+	default:
+		log.Panicf("Invalid rate limit action: %v", r.Action)
+	}
+	switch r.QuotaBy {
+	case "":
+		r.QuotaBy = quotaByDevice
+	case quotaByDevice, quotaByTenant:
+	default:
+		log.Panicf("Invalid quota key: %v", r.QuotaBy)
+	}
+}
+
+// configPipeline applies the environment and the defaults to the pipeline
+func configPipeline(p *PipelineConfig) {
+	p.Workers = GetEnvVarAsInt("GATEWAY_PIPELINE_WORKERS", p.Workers)
+	p.Buffer = GetEnvVarAsInt("GATEWAY_PIPELINE_BUFFER", p.Buffer)
+	p.SendBuffer = GetEnvVarAsInt("GATEWAY_PIPELINE_SEND_BUFFER", p.SendBuffer)
+	SetWithStringEnvVar("GATEWAY_PIPELINE_OVERFLOW", &p.Overflow)
+	p.Overflow = strings.ToLower(p.Overflow)
+
+	if p.Workers <= 0 {
+		p.Workers = defaultPipelineWorkers()
+	}
+	if p.Buffer <= 0 {
+		p.Buffer = defaultPipelineBuffer
+	}
+	if p.SendBuffer <= 0 {
+		p.SendBuffer = defaultSendBuffer
+	}
+	switch p.Overflow {
+	case "":
+		p.Overflow = overflowBlock
+	case overflowBlock, overflowDropOldest, overflowDropNewest, overflowDisconnect:
+	default:
+		log.Panicf("Invalid pipeline overflow policy: %v", p.Overflow)
+	}
+}
+
+// configIngest applies the environment and the defaults to the HTTP ingestion
+func configIngest(i *IngestConfig) {
+	i.Enabled = GetEnvVarAsBool("GATEWAY_INGEST", i.Enabled)
+	SetWithStringEnvVar("GATEWAY_INGEST_PATH", &i.Path)
+	i.MaxBatch = GetEnvVarAsInt("GATEWAY_INGEST_MAX_BATCH", i.MaxBatch)
+	i.MaxBodyKB = GetEnvVarAsInt("GATEWAY_INGEST_MAX_BODY_KB", i.MaxBodyKB)
+	i.Timeout = GetEnvVarAsInt("GATEWAY_INGEST_TIMEOUT", i.Timeout)
+
+	if len(i.Path) == 0 {
+		i.Path = defaultIngestPath
+	}
+	if i.MaxBatch <= 0 {
+		i.MaxBatch = defaultIngestMaxBatch
+	}
+	if i.MaxBodyKB <= 0 {
+		i.MaxBodyKB = defaultIngestMaxBodyKB
+	}
+	if i.Timeout <= 0 {
+		i.Timeout = defaultIngestTimeout
+	}
+}
+
+// configAdmin applies the environment to the admin API
+func configAdmin(a *AdminConfig) {
+	a.Enabled = GetEnvVarAsBool("GATEWAY_ADMIN", a.Enabled)
+	a.Port = GetEnvVarAsInt("GATEWAY_ADMIN_PORT", a.Port)
+	SetWithStringEnvVar("GATEWAY_ADMIN_TOKEN", &a.Token)
+
+	if a.Enabled && len(a.Token) == 0 {
+		log.Panicf("Admin API requires a token")
+	}
+}
+
+// parseFanoutTargets parses a comma separated list of backend[:policy] pairs
+func parseFanoutTargets(s string) []FanoutConfig {
+	var targets []FanoutConfig
+	for _, item := range strings.Split(s, ",") {
+		parts := strings.SplitN(strings.TrimSpace(item), ":", 2)
+		t := FanoutConfig{Backend: parts[0]}
+		if len(parts) > 1 {
+			t.Policy = parts[1]
+		}
+		targets = append(targets, t)
+	}
+	return targets
+}
+
+// configKafkaCredentials applies the TLS and SASL settings found
+// in the credentials of the bound Kafka service
+func configKafkaCredentials(creds map[string]interface{}, c *PubConfig) {
+	str := func(keys ...string) string {
+		for _, k := range keys {
+			if v, ok := creds[k].(string); ok && len(v) > 0 {
+				return v
+			}
+		}
+		return ""
+	}
+
+	if v := str("ca", "ca_cert", "ssl_ca"); len(v) > 0 {
+		c.TLS.Enabled = true
+		c.TLS.CA = v
+	}
+	if v := str("cert", "client_cert", "ssl_cert"); len(v) > 0 {
+		c.TLS.Enabled = true
+		c.TLS.Cert = v
+	}
+	if v := str("key", "client_key", "ssl_key"); len(v) > 0 {
+		c.TLS.Key = v
+	}
+	if v, ok := creds["tls"].(bool); ok {
+		c.TLS.Enabled = v
+	}
+	if v := str("sasl_mechanism", "mechanism"); len(v) > 0 {
+		c.SASL.Mechanism = v
+	}
+	if v := str("username", "user"); len(v) > 0 {
+		c.SASL.User = v
+		if len(c.SASL.Mechanism) == 0 {
+			c.SASL.Mechanism = saslPlain
+		}
+	}
+	if v := str("password"); len(v) > 0 {
+		c.SASL.Password = v
+	}
+}
+
+// validateKafkaAuth checks the TLS and SASL settings are complete
+func validateKafkaAuth(c *PubConfig) error {
+	if (len(c.TLS.Cert) > 0) != (len(c.TLS.Key) > 0) {
+		return fmt.Errorf("TLS client certificate and key must be set together")
+	}
+	c.SASL.Mechanism = strings.ToLower(c.SASL.Mechanism)
+	switch c.SASL.Mechanism {
+	case "":
+		return nil
+	case saslPlain, saslSCRAMSHA256, saslSCRAMSHA512:
+	default:
+		return fmt.Errorf("unsupported SASL mechanism: %v", c.SASL.Mechanism)
+	}
+	if len(c.SASL.User) == 0 || len(c.SASL.Password) == 0 {
+		return fmt.Errorf("SASL %s requires a user and a password", c.SASL.Mechanism)
+	}
+	return nil
 }
 
 // ServerConfig represents the Web server configuration holder
@@ -97,24 +460,254 @@ type ServerConfig struct {
 	AuthMethod      string `json:"auth_method"`
 	DeviceKeysURI   string `json:"device_keys_uri,omitempty"`
 	TolerableJWTAge int    `json:"tolerable_jwt_age,omitempty"`
+	ShutdownTimeout int    `json:"shutdown_timeout,omitempty"`
+	PingInterval    int    `json:"ping_interval,omitempty"`
+	ReadTimeout     int    `json:"read_timeout,omitempty"`
+	IdleTimeout     int    `json:"idle_timeout,omitempty"`
+
+	// limits of the connections, 0 means unlimited
+	MaxMessageKB         int `json:"max_message_kb,omitempty"`
+	MaxConnections       int `json:"max_connections,omitempty"`
+	MaxDeviceConnections int `json:"max_connections_per_device,omitempty"`
+	MaxIPConnections     int `json:"max_connections_per_ip,omitempty"`
+
+	// TrustedProxies is the number of proxies in front of the gateway
+	// appending to X-Forwarded-For, 0 ignores the header
+	TrustedProxies int `json:"trusted_proxies,omitempty"`
+
+	// TenantClaim is the JWT claim of the tenant a device belongs to
+	TenantClaim string          `json:"tenant_claim,omitempty"`
+	RateLimit   RateLimitConfig `json:"rate_limit,omitempty"`
+	Pipeline    PipelineConfig  `json:"pipeline,omitempty"`
+	Ingest      IngestConfig    `json:"ingest,omitempty"`
+}
+
+// IngestConfig represents the HTTP ingestion endpoint configuration holder
+type IngestConfig struct {
+	Enabled   bool   `json:"enabled,omitempty"`
+	Path      string `json:"path,omitempty"`
+	MaxBatch  int    `json:"max_batch,omitempty"`
+	MaxBodyKB int    `json:"max_body_kb,omitempty"`
+	Timeout   int    `json:"timeout,omitempty"`
+}
+
+// PipelineConfig represents the configuration holder of the buffers of the
+// connections and of the workers handing their messages to the publisher
+type PipelineConfig struct {
+	Workers    int    `json:"workers,omitempty"`
+	Buffer     int    `json:"buffer,omitempty"`
+	SendBuffer int    `json:"send_buffer,omitempty"`
+	Overflow   string `json:"overflow,omitempty"`
+}
+
+// RateLimitConfig represents the rate limit and quota configuration holder,
+// the limits are per second unless daily, 0 means unlimited
+type RateLimitConfig struct {
+	Enabled        bool   `json:"enabled,omitempty"`
+	Messages       int    `json:"messages_per_sec,omitempty"`
+	KB             int    `json:"kb_per_sec,omitempty"`
+	DeviceMessages int    `json:"device_messages_per_sec,omitempty"`
+	DeviceKB       int    `json:"device_kb_per_sec,omitempty"`
+	Action         string `json:"action,omitempty"`
+	DailyMessages  int64  `json:"daily_messages,omitempty"`
+	DailyMB        int64  `json:"daily_mb,omitempty"`
+	QuotaBy        string `json:"quota_by,omitempty"`
+	UsageFile      string `json:"usage_file,omitempty"`
+}
+
+// FileConfig represents the local file publisher configuration holder
+type FileConfig struct {
+	Dir         string `json:"dir,omitempty"`
+	Name        string `json:"name,omitempty"`
+	MaxSizeMB   int    `json:"max_size_mb,omitempty"`
+	RotateEvery int    `json:"rotate_every,omitempty"`
+	Gzip        bool   `json:"gzip,omitempty"`
+	Fsync       string `json:"fsync,omitempty"`
+	FsyncEvery  int    `json:"fsync_every,omitempty"`
+}
+
+// RedisConfig represents the Redis Streams publisher configuration holder
+type RedisConfig struct {
+	Address  string `json:"address,omitempty"`
+	Password string `json:"password,omitempty"`
+	DB       int    `json:"db,omitempty"`
+	Stream   string `json:"stream,omitempty"`
+	MaxLen   int    `json:"maxlen,omitempty"`
+	Pipeline int    `json:"pipeline,omitempty"`
+}
+
+// FanoutConfig represents a single backend of the fan-out publisher
+type FanoutConfig struct {
+	Backend string `json:"backend"`
+	Policy  string `json:"policy,omitempty"`
+	Buffer  int    `json:"buffer,omitempty"`
+}
+
+// SpoolConfig represents the on-disk spool configuration holder
+type SpoolConfig struct {
+	Enabled   bool   `json:"enabled,omitempty"`
+	Dir       string `json:"dir,omitempty"`
+	MaxSizeMB int    `json:"max_size_mb,omitempty"`
+	SegmentMB int    `json:"segment_mb,omitempty"`
+	Timeout   int    `json:"timeout_ms,omitempty"`
+}
+
+// DeadLetterConfig represents the dead-letter queue configuration holder
+type DeadLetterConfig struct {
+	Enabled bool   `json:"enabled,omitempty"`
+	Topic   string `json:"topic,omitempty"`
+	Dir     string `json:"dir,omitempty"`
+}
+
+// DownlinkConfig represents the downlink configuration holder
+type DownlinkConfig struct {
+	Enabled            bool   `json:"enabled,omitempty"`
+	Topic              string `json:"topic,omitempty"`
+	Group              string `json:"group,omitempty"`
+	UndeliverableTopic string `json:"undeliverable_topic,omitempty"`
+	Oldest             bool   `json:"oldest,omitempty"`
+}
+
+// PresenceConfig represents the presence events configuration holder
+type PresenceConfig struct {
+	Enabled bool   `json:"enabled,omitempty"`
+	Topic   string `json:"topic,omitempty"`
+}
+
+// WillConfig represents the last will configuration holder
+type WillConfig struct {
+	Enabled  bool   `json:"enabled,omitempty"`
+	Topic    string `json:"topic,omitempty"`
+	MaxBytes int    `json:"max_bytes,omitempty"`
+}
+
+// BreakerConfig represents the circuit breaker configuration holder
+type BreakerConfig struct {
+	Enabled   bool   `json:"enabled,omitempty"`
+	Errors    int    `json:"errors,omitempty"`
+	Successes int    `json:"successes,omitempty"`
+	Timeout   int    `json:"timeout_ms,omitempty"`
+	Latency   int    `json:"latency_ms,omitempty"`
+	Action    string `json:"action,omitempty"`
+}
+
+// TLSConfig represents the Kafka TLS configuration holder, certificates and
+// keys are either paths to PEM files or the PEM content itself
+type TLSConfig struct {
+	Enabled    bool   `json:"enabled,omitempty"`
+	CA         string `json:"ca,omitempty"`
+	Cert       string `json:"cert,omitempty"`
+	Key        string `json:"key,omitempty"`
+	ServerName string `json:"server_name,omitempty"`
+}
+
+// SASLConfig represents the Kafka SASL configuration holder
+type SASLConfig struct {
+	Mechanism string `json:"mechanism,omitempty"`
+	User      string `json:"user,omitempty"`
+	Password  string `json:"password,omitempty"`
+}
+
+// ProducerConfig represents the Kafka producer tuning holder
+type ProducerConfig struct {
+	Acks            string `json:"acks,omitempty"`
+	Compression     string `json:"compression,omitempty"`
+	FlushBytes      int    `json:"flush_bytes,omitempty"`
+	FlushMessages   int    `json:"flush_messages,omitempty"`
+	FlushMs         int    `json:"flush_ms,omitempty"`
+	MaxMessageBytes int    `json:"max_message_bytes,omitempty"`
+	Retries         *int   `json:"retries,omitempty"`
+	RetryBackoffMs  int    `json:"retry_backoff_ms,omitempty"`
+	Idempotent      bool   `json:"idempotent,omitempty"`
+	Partitioner     string `json:"partitioner,omitempty"`
+	Version         string `json:"version,omitempty"`
+}
+
+// RouteConfig represents the record layout of a Kafka topic, the headers
+// to attach and whether the record timestamp is set to the receive time
+type RouteConfig struct {
+	Headers   []string `json:"headers,omitempty"`
+	Timestamp *bool    `json:"timestamp,omitempty"`
 }
 
 // PubConfig represents the publisher configuration holder
 type PubConfig struct {
-	URI       []string `json:"uri,omitempty"`
-	Topic     string   `json:"topic,omitempty"`
-	Ack       bool     `json:"args,acks"`
-	Compress  bool     `json:"args,compress"`
-	FlushFreq int      `json:"args,flushevery"`
+	Backend    string                 `json:"backend,omitempty"`
+	File       FileConfig             `json:"file,omitempty"`
+	Redis      RedisConfig            `json:"redis,omitempty"`
+	Fanout     []FanoutConfig         `json:"fanout,omitempty"`
+	Spool      SpoolConfig            `json:"spool,omitempty"`
+	DeadLetter DeadLetterConfig       `json:"dead_letter,omitempty"`
+	Breaker    BreakerConfig          `json:"breaker,omitempty"`
+	Downlink   DownlinkConfig         `json:"downlink,omitempty"`
+	Presence   PresenceConfig         `json:"presence,omitempty"`
+	Will       WillConfig             `json:"will,omitempty"`
+	URI        []string               `json:"uri,omitempty"`
+	TLS        TLSConfig              `json:"tls,omitempty"`
+	SASL       SASLConfig             `json:"sasl,omitempty"`
+	Topic      string                 `json:"topic,omitempty"`
+	Producer   ProducerConfig         `json:"producer,omitempty"`
+	Routes     map[string]RouteConfig `json:"routes,omitempty"`
+	Ack        bool                   `json:"acks,omitempty"`
+	Compress   bool                   `json:"compress,omitempty"`
+	FlushFreq  int                    `json:"flushevery,omitempty"`
+}
+
+// AdminConfig represents the admin API configuration holder, the API is
+// served on the gateway port unless it has a port of its own
+type AdminConfig struct {
+	Enabled bool   `json:"enabled,omitempty"`
+	Host    string `json:"host,omitempty"`
+	Port    int    `json:"port,omitempty"`
+	Token   string `json:"token,omitempty"`
+}
+
+// LogConfig represents the logging configuration holder
+type LogConfig struct {
+	Level      string `json:"level,omitempty"`
+	Format     string `json:"format,omitempty"`
+	Bodies     string `json:"bodies,omitempty"`
+	SampleRate int    `json:"sample_rate,omitempty"`
+}
+
+// TracingConfig represents the tracing configuration holder
+type TracingConfig struct {
+	Exporter      string `json:"exporter,omitempty"`
+	Endpoint      string `json:"endpoint,omitempty"`
+	Service       string `json:"service,omitempty"`
+	SamplePercent *int   `json:"sample_percent,omitempty"`
 }
 
 // Config represents the root object configuraiton holder
 type Config struct {
-	ID     string       `json:"id,omitempty"`
-	Index  int          `json:"index,omitempty"`
-	Trace  bool         `json:"trace,omitempty"`
-	Server ServerConfig `json:"server,omitempty"`
-	Pub    PubConfig    `json:"publisher,omitempty"`
+	ID      string        `json:"id,omitempty"`
+	Index   int           `json:"index,omitempty"`
+	Trace   bool          `json:"trace,omitempty"`
+	Log     LogConfig     `json:"log,omitempty"`
+	Tracing TracingConfig `json:"tracing,omitempty"`
+	Admin   AdminConfig   `json:"admin,omitempty"`
+	Server  ServerConfig  `json:"server,omitempty"`
+	Pub     PubConfig     `json:"publisher,omitempty"`
+}
+
+// redacted returns a copy of the configuration safe to log, with the
+// passwords, tokens and inline private keys masked
+func (c Config) redacted() Config {
+	c.Server.Token = redact(c.Server.Token)
+	c.Admin.Token = redact(c.Admin.Token)
+	c.Pub.SASL.Password = redact(c.Pub.SASL.Password)
+	c.Pub.Redis.Password = redact(c.Pub.Redis.Password)
+	if strings.Contains(c.Pub.TLS.Key, "-----BEGIN") {
+		c.Pub.TLS.Key = redact(c.Pub.TLS.Key)
+	}
+	return c
+}
+
+func redact(s string) string {
+	if len(s) == 0 {
+		return s
+	}
+	return "REDACTED"
 }
 
 func loadConfig(path string, c *Config) {
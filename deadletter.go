@@ -0,0 +1,238 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// Stages of the pipeline a message can fail in
+const (
+	stageValidation = "validation"
+	stageDelivery   = "delivery"
+)
+
+const (
+	defaultDeadLetterName   = "deadletter"
+	defaultDeadLetterDir    = "./deadletter"
+	defaultDeadLetterBuffer = 256
+)
+
+var (
+	deadLetters       *DeadLetterQueue
+	deadLettersStored = newCounter("gateway_deadletters_total", "Number of dead letters by where they were stored.", "sink")
+)
+
+// DeadLetter is the record of a message which could not be published
+type DeadLetter struct {
+	ID       string    `json:"id"`
+	On       time.Time `json:"on"`
+	Body     string    `json:"body"`
+	Device   string    `json:"device,omitempty"`
+	Gateway  string    `json:"gateway"`
+	Topic    string    `json:"topic,omitempty"`
+	Stage    string    `json:"stage"`
+	Error    string    `json:"error"`
+	Attempts int       `json:"attempts"`
+	FailedOn time.Time `json:"failed_on"`
+}
+
+// DeadLetterQueue is the type representing the dead-letter queue, dead
+// letters are produced to a Kafka topic and written to a local file
+// when there is no topic or Kafka does not take them
+type DeadLetterQueue struct {
+	gateway  string
+	topic    string
+	in       chan []byte
+	producer sarama.AsyncProducer
+	file     *FilePublisher
+	done     chan bool
+
+	// handlers and the pipeline still fail messages while the gateway shuts down
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewDeadLetterQueue creates a new dead-letter queue object
+func NewDeadLetterQueue() *DeadLetterQueue {
+	return &DeadLetterQueue{}
+}
+
+// Config opens the fallback file and connects to Kafka when the
+// dead letters have a topic and the gateway publishes to Kafka
+func (q *DeadLetterQueue) Config(clientID string, args *PubConfig) {
+	conf := args.DeadLetter
+	q.gateway = clientID
+	q.topic = args.Topic
+
+	dir := conf.Dir
+	if len(dir) == 0 {
+		dir = defaultDeadLetterDir
+	}
+	fileArgs := *args
+	fileArgs.File = FileConfig{Dir: dir, Name: conf.Topic, Fsync: "always"}
+	if len(conf.Topic) == 0 {
+		fileArgs.File.Name = defaultDeadLetterName
+	}
+	q.file = NewFilePublisher().(*FilePublisher)
+	q.file.Config(clientID, &fileArgs)
+
+	if len(conf.Topic) == 0 || !usesBackend(args, "kafka") {
+		return
+	}
+
+	config, err := newKafkaConfig(clientID, args)
+	if err != nil {
+		logger.Fatalf("Invalid Kafka producer configuration: %v", err)
+	}
+	producer, err := sarama.NewAsyncProducer(args.URI, config)
+	if err != nil {
+		logger.Fatalf("Failed to start Kafka dead-letter producer: %v", err)
+	}
+	q.producer = producer
+	q.in = make(chan []byte, defaultDeadLetterBuffer)
+	newGaugeFunc("gateway_deadletter_queue_depth", "Number of dead letters waiting for Kafka.", func() float64 {
+		return float64(len(q.in))
+	})
+	q.done = make(chan bool)
+	go q.forward(conf.Topic)
+	go q.dispatch()
+}
+
+// Send records the failure of the message, it never blocks on Kafka
+func (q *DeadLetterQueue) Send(msg *Message, stage string, cause error, attempts int) {
+	if q == nil {
+		return
+	}
+	dl := &DeadLetter{
+		ID:       msg.ID,
+		On:       msg.On,
+		Body:     msg.Body,
+		Device:   msg.Device,
+		Gateway:  q.gateway,
+		Topic:    q.topic,
+		Stage:    stage,
+		Error:    cause.Error(),
+		Attempts: attempts,
+		FailedOn: time.Now().UTC(),
+	}
+	b, err := json.Marshal(dl)
+	if err != nil {
+		logger.Message(msg).Errorf("unable to marshal: %v", err)
+		return
+	}
+
+	if q.producer != nil {
+		q.mu.RLock()
+		if !q.closed {
+			select {
+			case q.in <- b:
+				q.mu.RUnlock()
+				return
+			default:
+				// Kafka is not keeping up, do not stall the pipeline
+			}
+		}
+		q.mu.RUnlock()
+	}
+	q.fallback(b)
+}
+
+// Close stops the producer once the pending dead letters are sent
+// and flushes the fallback file
+func (q *DeadLetterQueue) Close() error {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return nil
+	}
+	q.closed = true
+	if q.producer != nil {
+		close(q.in)
+	}
+	q.mu.Unlock()
+	if q.producer != nil {
+		<-q.done
+	}
+	return q.file.Close()
+}
+
+// forward produces the dead letters to the topic
+func (q *DeadLetterQueue) forward(topic string) {
+	for b := range q.in {
+		q.producer.Input() <- &sarama.ProducerMessage{
+			Topic:    topic,
+			Value:    sarama.ByteEncoder(b),
+			Metadata: b,
+		}
+	}
+	q.producer.AsyncClose()
+}
+
+// fallback writes the dead letter to the local file
+func (q *DeadLetterQueue) fallback(b []byte) {
+	if err := q.file.writeLine(b); err != nil {
+		logger.Errorf("Error on dead letter write for [%s]: %v", q.file.path, err)
+		deadLettersStored.with("failed").inc()
+		return
+	}
+	deadLettersStored.with("file").inc()
+	logger.Debugf("DeadLetter[%s] < %s", q.file.path, b)
+}
+
+// dispatch moves the dead letters Kafka failed to take to the local file
+func (q *DeadLetterQueue) dispatch() {
+	defer close(q.done)
+	successes, errs := q.producer.Successes(), q.producer.Errors()
+	for successes != nil || errs != nil {
+		select {
+		case m, ok := <-successes:
+			if !ok {
+				successes = nil
+				continue
+			}
+			deadLettersStored.with("kafka").inc()
+			logger.Debugf("DeadLetter[%s] < %s", m.Topic, m.Metadata)
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			logger.Errorf("Error on dead letter send for [%s]: %v", err.Msg.Topic, err.Err)
+			q.fallback(err.Msg.Metadata.([]byte))
+		}
+	}
+}
+
+// usesBackend tells whether the messages are published to the backend
+func usesBackend(args *PubConfig, backend string) bool {
+	if args.Backend != "fanout" {
+		return args.Backend == backend
+	}
+	for _, t := range args.Fanout {
+		if t.Backend == backend {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDeadLetterQueue(t *testing.T) (*DeadLetterQueue, string) {
+	dir, err := ioutil.TempDir("", "gateway-deadletter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := NewDeadLetterQueue()
+	q.Config("g1", &PubConfig{Backend: "file", Topic: "messages",
+		DeadLetter: DeadLetterConfig{Enabled: true, Dir: dir}})
+	return q, dir
+}
+
+func readDeadLetters(t *testing.T, path string) []DeadLetter {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var dls []DeadLetter
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var dl DeadLetter
+		assert.Nil(t, json.Unmarshal(scanner.Bytes(), &dl))
+		dls = append(dls, dl)
+	}
+	return dls
+}
+
+func TestDeadLetterQueue_File(t *testing.T) {
+	q, dir := newTestDeadLetterQueue(t)
+	defer os.RemoveAll(dir)
+
+	msg := NewMessage("payload")
+	msg.Device = "dev1"
+	q.Send(msg, stageDelivery, errors.New("boom"), 3)
+	assert.Nil(t, q.Close())
+
+	dls := readDeadLetters(t, filepath.Join(dir, defaultDeadLetterName+fileExt))
+	if assert.Len(t, dls, 1) {
+		dl := dls[0]
+		assert.Equal(t, msg.ID, dl.ID)
+		assert.Equal(t, "payload", dl.Body, "Dead letter must carry the original payload")
+		assert.Equal(t, "dev1", dl.Device)
+		assert.Equal(t, "g1", dl.Gateway)
+		assert.Equal(t, "messages", dl.Topic)
+		assert.Equal(t, stageDelivery, dl.Stage)
+		assert.Equal(t, "boom", dl.Error)
+		assert.Equal(t, 3, dl.Attempts)
+	}
+}
+
+func TestDeadLetterQueue_KafkaFallback(t *testing.T) {
+	q, dir := newTestDeadLetterQueue(t)
+	defer os.RemoveAll(dir)
+
+	config := sarama.NewConfig()
+	config.Producer.Return.Successes = true
+	producer := mocks.NewAsyncProducer(t, config)
+	producer.ExpectInputAndSucceed()
+	producer.ExpectInputAndFail(errors.New("kafka is down"))
+
+	q.producer = producer
+	q.in = make(chan []byte, 2)
+	q.done = make(chan bool)
+	go q.forward("dlq")
+	go q.dispatch()
+
+	q.Send(NewMessage("one"), stageValidation, errors.New("invalid"), 1)
+	q.Send(NewMessage("two"), stageValidation, errors.New("invalid"), 1)
+	assert.Nil(t, q.Close())
+	assert.Nil(t, q.Close(), "Closing twice must not panic")
+	q.Send(NewMessage("late"), stageDelivery, errors.New("closed"), 1)
+
+	dls := readDeadLetters(t, filepath.Join(dir, defaultDeadLetterName+fileExt))
+	if assert.Len(t, dls, 1, "Dead letters Kafka failed to take must be written to the file") {
+		assert.Equal(t, "two", dls[0].Body)
+		assert.Equal(t, stageValidation, dls[0].Stage)
+	}
+}
+
+func TestDeadLetterQueue_Disabled(t *testing.T) {
+	var q *DeadLetterQueue
+	q.Send(NewMessage("one"), stageDelivery, errors.New("boom"), 1)
+}
+
+func TestUsesBackend(t *testing.T) {
+	assert.True(t, usesBackend(&PubConfig{Backend: "kafka"}, "kafka"))
+	assert.False(t, usesBackend(&PubConfig{Backend: "file"}, "kafka"))
+	assert.True(t, usesBackend(&PubConfig{Backend: "fanout",
+		Fanout: []FanoutConfig{{Backend: "file"}, {Backend: "kafka"}}}, "kafka"))
+}
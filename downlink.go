@@ -0,0 +1,433 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+const (
+	defaultDownlinkGroup = "gateway-downlink-{id}"
+
+	// reasons a command could not be delivered
+	undeliverableNoDevice = "no_device"
+	undeliverableOffline  = "offline"
+	undeliverableBacklog  = "backlog"
+	undeliverableRemote   = "remote"
+
+	headerReason = "reason"
+)
+
+var (
+	downlinkCommands = newCounter("gateway_downlink_commands_total",
+		"Commands consumed from the downlink topic by result.", "result")
+
+	// downlink delivers the commands to the devices when enabled
+	downlink *Downlink
+)
+
+// commandFrame is sent to the device for each of its commands
+type commandFrame struct {
+	Type string          `json:"type"`
+	ID   string          `json:"id"`
+	Body json.RawMessage `json:"body"`
+}
+
+// Downlink consumes the command topic and delivers each command to the
+// connections of its device, the commands which could not be delivered
+// are produced to the undeliverable topic
+type Downlink struct {
+	broker        *broker
+	gateway       string
+	undeliverable string
+	client        sarama.Client
+	group         sarama.ConsumerGroup
+	shared        bool
+	owners        *deviceOwners
+	watcher       sarama.Consumer
+	producer      sarama.AsyncProducer
+	cancel        context.CancelFunc
+	done          chan bool
+	flushed       chan bool
+}
+
+// newDownlink connects to Kafka and starts consuming the command topic
+func newDownlink(b *broker, clientID string, args *PubConfig) *Downlink {
+	conf := args.Downlink
+	config, err := newKafkaConfig(clientID, args)
+	if err != nil {
+		logger.Fatalf("Invalid Kafka downlink configuration: %v", err)
+	}
+	if !config.Version.IsAtLeast(sarama.V0_10_2_0) {
+		logger.Fatalf("Kafka downlink requires version 0.10.2 or newer")
+	}
+	config.Consumer.Return.Errors = true
+	config.Consumer.Offsets.Initial = sarama.OffsetNewest
+	if conf.Oldest {
+		config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	}
+
+	client, err := sarama.NewClient(args.URI, config)
+	if err != nil {
+		logger.Fatalf("Failed to connect to Kafka: %v", err)
+	}
+	group, err := sarama.NewConsumerGroupFromClient(downlinkGroup(conf.Group, clientID), client)
+	if err != nil {
+		logger.Fatalf("Failed to start Kafka downlink consumer: %v", err)
+	}
+
+	d := &Downlink{
+		broker:        b,
+		gateway:       clientID,
+		undeliverable: conf.UndeliverableTopic,
+		client:        client,
+		group:         group,
+		shared:        len(conf.Group) > 0 && !strings.Contains(conf.Group, "{id}"),
+		owners:        newDeviceOwners(),
+		done:          make(chan bool),
+		flushed:       make(chan bool),
+	}
+	if args.Presence.Enabled && usesBackend(args, "kafka") {
+		if err := d.watch(args.Presence.Topic); err != nil {
+			logger.Fatalf("Failed to consume the presence topic: %v", err)
+		}
+	} else if !d.shared {
+		logger.Warnf("Downlink without presence events reports the commands of any device not connected here, it assumes a single gateway")
+	}
+	if len(d.undeliverable) > 0 {
+		if d.producer, err = sarama.NewAsyncProducerFromClient(client); err != nil {
+			logger.Fatalf("Failed to start Kafka undeliverable producer: %v", err)
+		}
+		go d.dispatch()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+	go d.consume(ctx, conf.Topic)
+	go func() {
+		for err := range group.Errors() {
+			logger.Errorf("Error on downlink consume for [%s]: %v", conf.Topic, err)
+		}
+	}()
+	return d
+}
+
+// downlinkGroup returns the consumer group, {id} is replaced with the id
+// of the gateway so each instance can consume all of the commands
+func downlinkGroup(group, clientID string) string {
+	if len(group) == 0 {
+		group = defaultDownlinkGroup
+	}
+	return strings.Replace(group, "{id}", clientID, -1)
+}
+
+// watch follows the presence events of all of the gateways from the oldest
+// one to learn which gateway each device is connected to
+func (d *Downlink) watch(topic string) error {
+	consumer, err := sarama.NewConsumerFromClient(d.client)
+	if err != nil {
+		return err
+	}
+	partitions, err := d.client.Partitions(topic)
+	if err != nil {
+		consumer.Close()
+		return err
+	}
+	for _, partition := range partitions {
+		pc, err := consumer.ConsumePartition(topic, partition, sarama.OffsetOldest)
+		if err != nil {
+			consumer.Close()
+			return err
+		}
+		go func() {
+			for m := range pc.Messages() {
+				var e PresenceEvent
+				if err := json.Unmarshal(m.Value, &e); err != nil {
+					logger.Errorf("Error on presence event at %d/%d: %v", m.Partition, m.Offset, err)
+					continue
+				}
+				d.owners.apply(&e)
+			}
+		}()
+	}
+	d.watcher = consumer
+	return nil
+}
+
+// consume joins the consumer group until the downlink is closed,
+// the session is joined again after every rebalance
+func (d *Downlink) consume(ctx context.Context, topic string) {
+	defer close(d.done)
+	for {
+		if err := d.group.Consume(ctx, []string{topic}, d); err != nil {
+			logger.Errorf("Error on downlink session for [%s]: %v", topic, err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// Setup is run at the beginning of a new session
+func (d *Downlink) Setup(sarama.ConsumerGroupSession) error { return nil }
+
+// Cleanup is run at the end of a session
+func (d *Downlink) Cleanup(sarama.ConsumerGroupSession) error { return nil }
+
+// ConsumeClaim delivers the commands of the claimed partition
+func (d *Downlink) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for m := range claim.Messages() {
+		d.handle(m)
+		sess.MarkMessage(m, "")
+	}
+	return nil
+}
+
+// handle delivers the command to the connections of its device, the device
+// is read from the device header or else from the record key
+func (d *Downlink) handle(m *sarama.ConsumerMessage) {
+	device := recordHeader(m, headerDevice)
+	if len(device) == 0 {
+		device = string(m.Key)
+	}
+	id := recordHeader(m, headerID)
+	if len(id) == 0 {
+		id = fmt.Sprintf("%s-%d-%d", m.Topic, m.Partition, m.Offset)
+	}
+	log := logger.With("device", device).With("msg_id", id)
+
+	if len(device) == 0 {
+		d.reject(m, device, undeliverableNoDevice)
+		return
+	}
+	frame := commandFrame{Type: "command", ID: id, Body: json.RawMessage(m.Value)}
+	if !json.Valid(m.Value) {
+		frame.Body, _ = json.Marshal(string(m.Value))
+	}
+
+	reason := undeliverableOffline
+	for _, c := range d.broker.list() {
+		if c.device != device {
+			continue
+		}
+		if c.deliver(frame) {
+			reason = ""
+		} else if len(reason) > 0 {
+			reason = undeliverableBacklog
+		}
+	}
+	if reason == undeliverableOffline {
+		// every gateway gets the command unless the group is shared,
+		// only one of them reports it
+		switch {
+		case d.owners.elsewhere(device, d.gateway) && d.shared:
+			reason = undeliverableRemote
+		case d.owners.elsewhere(device, d.gateway), !d.shared && !d.owners.reports(device, d.gateway):
+			downlinkCommands.with("remote").inc()
+			log.Debugf("Downlink[%s] command left to another gateway", m.Topic)
+			return
+		}
+	}
+	if len(reason) > 0 {
+		d.reject(m, device, reason)
+		return
+	}
+	downlinkCommands.with("delivered").inc()
+	log.Debugf("Downlink[%s] > %s", m.Topic, logBody(string(m.Value)))
+}
+
+// reject produces the command to the undeliverable topic with the reason
+func (d *Downlink) reject(m *sarama.ConsumerMessage, device, reason string) {
+	downlinkCommands.with("undeliverable").inc()
+	logger.With("device", device).Debugf("Downlink[%s] command at %d/%d undeliverable: %s",
+		m.Topic, m.Partition, m.Offset, reason)
+	if d.producer == nil {
+		return
+	}
+	headers := []sarama.RecordHeader{
+		{Key: []byte(headerReason), Value: []byte(reason)},
+		{Key: []byte(headerGateway), Value: []byte(d.gateway)},
+	}
+	for _, h := range m.Headers {
+		switch string(h.Key) {
+		case headerReason, headerGateway:
+		default:
+			headers = append(headers, *h)
+		}
+	}
+	r := &sarama.ProducerMessage{
+		Topic:   d.undeliverable,
+		Value:   sarama.ByteEncoder(m.Value),
+		Headers: headers,
+	}
+	if m.Key != nil {
+		r.Key = sarama.ByteEncoder(m.Key)
+	}
+	d.producer.Input() <- r
+}
+
+// dispatch drains the results of the undeliverable producer
+func (d *Downlink) dispatch() {
+	defer close(d.flushed)
+	successes, errs := d.producer.Successes(), d.producer.Errors()
+	for successes != nil || errs != nil {
+		select {
+		case _, ok := <-successes:
+			if !ok {
+				successes = nil
+			}
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			logger.Errorf("Error on undeliverable send for [%s]: %v", err.Msg.Topic, err.Err)
+		}
+	}
+}
+
+// Close leaves the consumer group and flushes the undeliverable commands
+func (d *Downlink) Close() error {
+	if d == nil {
+		return nil
+	}
+	d.cancel()
+	err := d.group.Close()
+	<-d.done
+	if d.watcher != nil {
+		d.watcher.Close()
+	}
+	if d.producer != nil {
+		d.producer.AsyncClose()
+		<-d.flushed
+	}
+	if e := d.client.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// recordHeader returns the value of the record header, empty when missing
+func recordHeader(m *sarama.ConsumerMessage, key string) string {
+	for _, h := range m.Headers {
+		if h != nil && string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// ownerConn is a connection of a device to a gateway
+type ownerConn struct {
+	gateway string
+	conn    int64
+}
+
+// deviceOwners tracks the gateways the devices are connected to from the
+// presence events of all of the gateways
+type deviceOwners struct {
+	mu       sync.RWMutex
+	conns    map[string]map[ownerConn]bool
+	last     map[string]string
+	gateways map[string]bool
+}
+
+func newDeviceOwners() *deviceOwners {
+	return &deviceOwners{
+		conns:    make(map[string]map[ownerConn]bool),
+		last:     make(map[string]string),
+		gateways: make(map[string]bool),
+	}
+}
+
+// apply updates the connections with the presence event
+func (o *deviceOwners) apply(e *PresenceEvent) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.gateways[e.Gateway] = true
+	switch e.Type {
+	case presenceStarted:
+		// the connections of the previous run of the gateway are gone
+		for device, conns := range o.conns {
+			for c := range conns {
+				if c.gateway == e.Gateway {
+					delete(conns, c)
+				}
+			}
+			if len(conns) == 0 {
+				delete(o.conns, device)
+			}
+		}
+	case presenceConnected:
+		if o.conns[e.Device] == nil {
+			o.conns[e.Device] = make(map[ownerConn]bool)
+		}
+		o.conns[e.Device][ownerConn{e.Gateway, e.Conn}] = true
+		o.last[e.Device] = e.Gateway
+	case presenceDisconnected:
+		delete(o.conns[e.Device], ownerConn{e.Gateway, e.Conn})
+		if len(o.conns[e.Device]) == 0 {
+			delete(o.conns, e.Device)
+		}
+		o.last[e.Device] = e.Gateway
+	}
+}
+
+// elsewhere tells whether the device is connected to another gateway
+func (o *deviceOwners) elsewhere(device, gateway string) bool {
+	if o == nil {
+		return false
+	}
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	for c := range o.conns[device] {
+		if c.gateway != gateway {
+			return true
+		}
+	}
+	return false
+}
+
+// reports tells whether the gateway reports the commands of a device which
+// is not connected anywhere: the gateway the device was last connected to
+// does, the first of the known gateways for the devices never seen
+func (o *deviceOwners) reports(device, gateway string) bool {
+	if o == nil {
+		return true
+	}
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	if last, ok := o.last[device]; ok {
+		return last == gateway
+	}
+	for g := range o.gateways {
+		if g < gateway {
+			return false
+		}
+	}
+	return true
+}
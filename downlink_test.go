@@ -0,0 +1,139 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"testing"
+	"time"
+
+	"code.google.com/p/go.net/websocket"
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownlink_Handle(t *testing.T) {
+	pub = &ackPublisher{}
+	b := newBroker()
+	b.authVal = deviceAuth{}
+	ts, _ := serveTestBroker(t, b)
+	defer ts.Close()
+	ws := dialTestServer(t, ts, "/?device=dev1")
+	defer ws.Close()
+	ws.SetDeadline(time.Now().Add(5 * time.Second))
+	waitClients(t, b, 1)
+
+	config := sarama.NewConfig()
+	config.Producer.Return.Successes = true
+	producer := mocks.NewAsyncProducer(t, config)
+	d := &Downlink{broker: b, gateway: "g1", undeliverable: "undeliverable",
+		producer: producer, flushed: make(chan bool)}
+	go d.dispatch()
+
+	d.handle(&sarama.ConsumerMessage{Topic: "commands", Key: []byte("dev1"), Value: []byte(`{"reboot":true}`),
+		Headers: []*sarama.RecordHeader{{Key: []byte(headerID), Value: []byte("c1")}}})
+	var frame commandFrame
+	assert.Nil(t, websocket.JSON.Receive(ws, &frame))
+	assert.Equal(t, commandFrame{Type: "command", ID: "c1", Body: []byte(`{"reboot":true}`)}, frame)
+
+	d.handle(&sarama.ConsumerMessage{Topic: "commands", Partition: 1, Offset: 7, Value: []byte("plain"),
+		Headers: []*sarama.RecordHeader{{Key: []byte(headerDevice), Value: []byte("dev1")}}})
+	assert.Nil(t, websocket.JSON.Receive(ws, &frame))
+	assert.Equal(t, "commands-1-7", frame.ID, "Commands without an id must be identified by their offset")
+	assert.Equal(t, `"plain"`, string(frame.Body), "Plain text commands must be sent as a string")
+
+	producer.ExpectInputAndSucceed()
+	d.handle(&sarama.ConsumerMessage{Topic: "commands", Key: []byte("dev2"), Value: []byte("x")})
+	producer.ExpectInputAndSucceed()
+	d.handle(&sarama.ConsumerMessage{Topic: "commands", Value: []byte("x")})
+
+	producer.AsyncClose()
+	<-d.flushed
+}
+
+func TestDownlink_Reject(t *testing.T) {
+	config := sarama.NewConfig()
+	config.Producer.Return.Successes = true
+	producer := mocks.NewAsyncProducer(t, config)
+	d := &Downlink{gateway: "g1", undeliverable: "undeliverable", producer: producer}
+
+	producer.ExpectInputWithCheckerFunctionAndSucceed(func(val []byte) error {
+		assert.Equal(t, "x", string(val))
+		return nil
+	})
+	d.reject(&sarama.ConsumerMessage{Topic: "commands", Key: []byte("dev2"), Value: []byte("x"),
+		Headers: []*sarama.RecordHeader{{Key: []byte(headerReason), Value: []byte("spoofed")}}}, "dev2", undeliverableOffline)
+	m := <-producer.Successes()
+	assert.Equal(t, "undeliverable", m.Topic)
+	assert.Equal(t, []sarama.RecordHeader{
+		{Key: []byte(headerReason), Value: []byte(undeliverableOffline)},
+		{Key: []byte(headerGateway), Value: []byte("g1")},
+	}, m.Headers)
+	assert.Nil(t, producer.Close())
+}
+
+func TestDownlinkGroup(t *testing.T) {
+	assert.Equal(t, "gateway-downlink-g1", downlinkGroup("", "g1"), "Every gateway must get all of the commands by default")
+	assert.Equal(t, "downlink-g1", downlinkGroup("downlink-{id}", "g1"))
+
+	var c Config
+	loadConfig("defaults.json", &c)
+	assert.Equal(t, "gateway-downlink-g1", downlinkGroup(c.Pub.Downlink.Group, "g1"), "The defaults must give every gateway its own group")
+}
+
+func TestDownlink_Owners(t *testing.T) {
+	config := sarama.NewConfig()
+	config.Producer.Return.Successes = true
+	producer := mocks.NewAsyncProducer(t, config)
+	b := newBroker()
+	ts, _ := serveTestBroker(t, b)
+	defer ts.Close()
+	d := &Downlink{broker: b, gateway: "g1", undeliverable: "undeliverable",
+		owners: newDeviceOwners(), producer: producer}
+
+	d.owners.apply(&PresenceEvent{Type: presenceStarted, Gateway: "g0"})
+	d.owners.apply(&PresenceEvent{Type: presenceConnected, Gateway: "g2", Device: "dev2", Conn: 1})
+	d.owners.apply(&PresenceEvent{Type: presenceConnected, Gateway: "g1", Device: "dev3", Conn: 1})
+	d.owners.apply(&PresenceEvent{Type: presenceDisconnected, Gateway: "g1", Device: "dev3", Conn: 1})
+	d.owners.apply(&PresenceEvent{Type: presenceConnected, Gateway: "g3", Device: "dev5", Conn: 1})
+	d.owners.apply(&PresenceEvent{Type: presenceStarted, Gateway: "g3"})
+	assert.True(t, d.owners.elsewhere("dev2", "g1"))
+	assert.False(t, d.owners.elsewhere("dev5", "g1"), "Connections of a restarted gateway must be gone")
+
+	// only the gateway the device was last connected to reports it offline
+	producer.ExpectInputWithCheckerFunctionAndSucceed(func(val []byte) error {
+		assert.Equal(t, "for dev3", string(val))
+		return nil
+	})
+	d.handle(&sarama.ConsumerMessage{Topic: "commands", Key: []byte("dev2"), Value: []byte("for dev2")})
+	d.handle(&sarama.ConsumerMessage{Topic: "commands", Key: []byte("dev3"), Value: []byte("for dev3")})
+	d.handle(&sarama.ConsumerMessage{Topic: "commands", Key: []byte("dev4"), Value: []byte("for dev4")})
+	d.handle(&sarama.ConsumerMessage{Topic: "commands", Key: []byte("dev5"), Value: []byte("for dev5")})
+	<-producer.Successes()
+	assert.False(t, d.owners.reports("dev4", "g1"), "Unknown devices must be reported by the first gateway")
+	assert.True(t, d.owners.reports("dev4", "g0"))
+
+	// with a shared group the command is not delivered by anyone else
+	d.shared = true
+	producer.ExpectInputWithCheckerFunctionAndSucceed(func(val []byte) error {
+		assert.Equal(t, "for dev2", string(val))
+		return nil
+	})
+	d.handle(&sarama.ConsumerMessage{Topic: "commands", Key: []byte("dev2"), Value: []byte("for dev2")})
+	m := <-producer.Successes()
+	assert.Equal(t, []byte(undeliverableRemote), m.Headers[0].Value)
+	assert.Nil(t, producer.Close())
+}
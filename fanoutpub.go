@@ -0,0 +1,176 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"sync"
+)
+
+const (
+	fanoutRequired      = "required"
+	fanoutBestEffort    = "best-effort"
+	defaultFanoutBuffer = 1000
+)
+
+var (
+	fanoutSent    = newCounter("gateway_fanout_sent_total", "Number of messages queued for each fan-out backend.", "backend")
+	fanoutDropped = newCounter("gateway_fanout_dropped_total", "Number of messages dropped by best-effort fan-out backends.", "backend")
+)
+
+// fanoutTarget is a single backend of the fan-out publisher, each one is fed
+// from its own buffer so a slow backend does not stall the others
+type fanoutTarget struct {
+	name     string
+	required bool
+	pub      Publisher
+	ch       chan *Message
+}
+
+// offer queues the message for the target, required targets wait for room
+// in the buffer while best-effort ones drop the message when it is full
+func (t *fanoutTarget) offer(msg *Message) {
+	if t.required {
+		t.ch <- msg
+		fanoutSent.with(t.name).inc()
+		return
+	}
+	select {
+	case t.ch <- msg:
+		fanoutSent.with(t.name).inc()
+	default:
+		fanoutDropped.with(t.name).inc()
+		logger.Message(msg).Debugf("Fanout[%s] dropped msg", t.name)
+	}
+}
+
+// FanoutPublisher is the type representing fan-out publisher imp,
+// it delivers each message to all of the configured backends
+type FanoutPublisher struct {
+	targets  []*fanoutTarget
+	required int
+}
+
+// NewFanoutPublisher creates a new fan-out publisher object
+func NewFanoutPublisher() Publisher {
+	return &FanoutPublisher{}
+}
+
+// Config configures and starts each of the fan-out backends
+func (p *FanoutPublisher) Config(clientID string, args *PubConfig) {
+	for _, c := range args.Fanout {
+		logger.Infof("Fanning out to %s publisher (%s)", c.Backend, c.Policy)
+		target := newPublisher(c.Backend)
+		target.Config(clientID, args)
+		p.add(c.Backend, target, c.Policy == fanoutRequired, c.Buffer)
+	}
+}
+
+// Close closes each of the fan-out backends
+func (p *FanoutPublisher) Close() error {
+	var err error
+	for _, t := range p.targets {
+		if cerr := closePublisher(t.pub); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// health reports the state of the required backends, best-effort
+// backends do not affect the readiness of the gateway
+func (p *FanoutPublisher) health(status map[string]error) {
+	for _, t := range p.targets {
+		if t.required {
+			checkHealth(t.pub, status)
+		}
+	}
+}
+
+func (p *FanoutPublisher) add(name string, pub Publisher, required bool, buffer int) {
+	if buffer <= 0 {
+		buffer = defaultFanoutBuffer
+	}
+	t := &fanoutTarget{
+		name:     name,
+		required: required,
+		pub:      pub,
+		ch:       make(chan *Message, buffer),
+	}
+
+	p.targets = append(p.targets, t)
+	if required {
+		p.required++
+	}
+	targets := p.targets
+	newCollector("gateway_fanout_queue_depth", "Number of messages queued for each fan-out backend.",
+		metricGauge, []string{"backend"}, func() []metricSample {
+			samples := make([]metricSample, 0, len(targets))
+			for _, t := range targets {
+				samples = append(samples, metricSample{labels: []string{t.name}, value: float64(len(t.ch))})
+			}
+			return samples
+		})
+	go pub.Start(t.ch)
+}
+
+// Start fires a publisher listener
+func (p *FanoutPublisher) Start(in <-chan *Message) {
+	for msg := range in {
+		if msg.delivered == nil {
+			for _, t := range p.targets {
+				t.offer(msg)
+			}
+			continue
+		}
+
+		// each backend gets its own copy so the delivery is reported
+		// only once all of the required backends confirmed it
+		delivered := joinDelivery(msg, p.required)
+		for _, t := range p.targets {
+			m := &Message{ID: msg.ID, On: msg.On, Body: msg.Body,
+				Device: msg.Device, Auth: msg.Auth, Trace: msg.Trace}
+			if t.required {
+				m.delivered = delivered
+			}
+			t.offer(m)
+		}
+		if p.required == 0 {
+			msg.Delivered(nil)
+		}
+	}
+}
+
+// joinDelivery reports the delivery of msg once n of its copies were delivered,
+// the first failure is reported right away
+func joinDelivery(msg *Message, n int) func(err error) {
+	var mu sync.Mutex
+	left := n
+	return func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if left <= 0 {
+			return
+		}
+		if err != nil {
+			left = 0
+			msg.Delivered(err)
+			return
+		}
+		if left--; left == 0 {
+			msg.Delivered(nil)
+		}
+	}
+}
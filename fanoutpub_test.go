@@ -0,0 +1,64 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// chanPublisher hands every message it gets over to out,
+// it never reads its input while out is nil
+type chanPublisher struct {
+	out chan *Message
+}
+
+func (p *chanPublisher) Config(clientID string, args *PubConfig) {}
+func (p *chanPublisher) Start(in <-chan *Message) {
+	for msg := range in {
+		p.out <- msg
+	}
+}
+
+func TestFanoutPublisher_Start(t *testing.T) {
+	fast := &chanPublisher{out: make(chan *Message, 10)}
+	stalled := &chanPublisher{}
+
+	sentBefore := fanoutSent.with("stalled").value()
+	droppedBefore := fanoutDropped.with("stalled").value()
+	p := &FanoutPublisher{}
+	p.add("stalled", stalled, false, 1)
+	p.add("fast", fast, true, 10)
+
+	in := make(chan *Message)
+	go p.Start(in)
+
+	sent := []*Message{NewMessage("one"), NewMessage("two"), NewMessage("three")}
+	for _, msg := range sent {
+		in <- msg
+	}
+	close(in)
+
+	for _, msg := range sent {
+		assert.Equal(t, msg, <-fast.out, "Required backend must get every message in order")
+	}
+
+	// the stalled backend may have taken the first message out of its buffer
+	dropped := fanoutDropped.with("stalled").value() - droppedBefore
+	assert.True(t, dropped >= 1, "Best-effort backend must drop when its buffer is full")
+	assert.Equal(t, float64(3), fanoutSent.with("stalled").value()-sentBefore+dropped)
+}
@@ -0,0 +1,257 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	fileExt           = ".ndjson"
+	fileRollTimestamp = "20060102T150405.000000000Z"
+	defaultFileMaxMB  = 64
+)
+
+// FilePublisher is the type representing local file publisher imp,
+// it appends each envelope as a single line of JSON (NDJSON) and rolls
+// the active file over once it gets too big or too old
+type FilePublisher struct {
+	mu     sync.Mutex
+	conf   FileConfig
+	path   string
+	file   *os.File
+	size   int64
+	opened time.Time
+	dirty  bool
+	closed bool
+	done   chan bool
+	once   sync.Once
+	rolls  sync.WaitGroup
+}
+
+// NewFilePublisher creates a new local file publisher object
+func NewFilePublisher() Publisher {
+	return &FilePublisher{}
+}
+
+// Config opens the active file in the configured directory
+func (p *FilePublisher) Config(clientID string, args *PubConfig) {
+	p.conf = args.File
+	if len(p.conf.Dir) == 0 {
+		p.conf.Dir = "."
+	}
+	if len(p.conf.Name) == 0 {
+		p.conf.Name = args.Topic
+	}
+	if p.conf.MaxSizeMB <= 0 {
+		p.conf.MaxSizeMB = defaultFileMaxMB
+	}
+	if len(p.conf.Fsync) == 0 {
+		p.conf.Fsync = "interval"
+	}
+	if p.conf.FsyncEvery <= 0 {
+		p.conf.FsyncEvery = 1
+	}
+	p.path = filepath.Join(p.conf.Dir, p.conf.Name+fileExt)
+
+	if err := os.MkdirAll(p.conf.Dir, 0755); err != nil {
+		logger.Fatalf("Failed to create file publisher directory: %v", err)
+	}
+	if err := p.open(); err != nil {
+		logger.Fatalf("Failed to open file publisher output: %v", err)
+	}
+
+	p.done = make(chan bool)
+	go p.maintain()
+}
+
+// Start fires a publisher listener
+func (p *FilePublisher) Start(in <-chan *Message) {
+	for msg := range in {
+		err := p.write(msg)
+		recordDelivery("file", p.conf.Name, msg, err)
+		msg.Delivered(err)
+		if err != nil {
+			logger.Message(msg).Errorf("Error on file write for [%s]: %v", p.path, err)
+			continue
+		}
+		logger.Message(msg).Debugf("File[%s] < %s", p.path, logBody(msg.Body))
+	}
+}
+
+// Close flushes the active file to disk and waits for pending rollovers
+func (p *FilePublisher) Close() error {
+	// closed both on shutdown and by the fan-out publisher
+	var err error
+	p.once.Do(func() {
+		p.mu.Lock()
+		p.closed = true
+		close(p.done)
+		err = p.sync()
+		if cerr := p.file.Close(); err == nil {
+			err = cerr
+		}
+		p.mu.Unlock()
+	})
+	p.rolls.Wait()
+	return err
+}
+
+func (p *FilePublisher) write(msg *Message) error {
+	return p.writeLine(msg.ToBytes())
+}
+
+// writeLine appends a single JSON document to the active file
+func (p *FilePublisher) writeLine(b []byte) error {
+	line := append(b, '\n')
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.size > 0 && p.size+int64(len(line)) > int64(p.conf.MaxSizeMB)<<20 {
+		if err := p.roll(); err != nil {
+			return err
+		}
+	}
+
+	n, err := p.file.Write(line)
+	p.size += int64(n)
+	p.dirty = true
+	if err != nil {
+		return err
+	}
+	if p.conf.Fsync == "always" {
+		return p.sync()
+	}
+	return nil
+}
+
+// maintain handles the interval based fsync and the time based rollover
+func (p *FilePublisher) maintain() {
+	ticker := time.NewTicker(time.Duration(p.conf.FsyncEvery) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.mu.Lock()
+			if p.closed {
+				p.mu.Unlock()
+				return
+			}
+			if p.conf.Fsync == "interval" {
+				if err := p.sync(); err != nil {
+					logger.Errorf("Error on file sync for [%s]: %v", p.path, err)
+				}
+			}
+			if p.conf.RotateEvery > 0 && p.size > 0 &&
+				time.Since(p.opened) >= time.Duration(p.conf.RotateEvery)*time.Second {
+				if err := p.roll(); err != nil {
+					logger.Errorf("Error on file rollover for [%s]: %v", p.path, err)
+				}
+			}
+			p.mu.Unlock()
+		case <-p.done:
+			return
+		}
+	}
+}
+
+func (p *FilePublisher) open() error {
+	f, err := os.OpenFile(p.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	p.file = f
+	p.size = info.Size()
+	p.opened = time.Now()
+	return nil
+}
+
+func (p *FilePublisher) sync() error {
+	if !p.dirty || p.conf.Fsync == "never" {
+		return nil
+	}
+	p.dirty = false
+	return p.file.Sync()
+}
+
+// roll renames the active file with a timestamp suffix and opens a fresh one,
+// must be called with the lock held
+func (p *FilePublisher) roll() error {
+	if err := p.sync(); err != nil {
+		return err
+	}
+	if err := p.file.Close(); err != nil {
+		return err
+	}
+	rolled := filepath.Join(p.conf.Dir, fmt.Sprintf("%s-%s%s",
+		p.conf.Name, time.Now().UTC().Format(fileRollTimestamp), fileExt))
+	if err := os.Rename(p.path, rolled); err != nil {
+		return err
+	}
+	if p.conf.Gzip {
+		p.rolls.Add(1)
+		go func() {
+			defer p.rolls.Done()
+			if err := gzipFile(rolled); err != nil {
+				logger.Errorf("Error on file compress for [%s]: %v", rolled, err)
+			}
+		}()
+	}
+	return p.open()
+}
+
+// gzipFile compresses the file at path into path.gz and removes the original
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Sync(); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,107 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestFilePublisher(t *testing.T, conf FileConfig) (*FilePublisher, string) {
+	dir, err := ioutil.TempDir("", "gateway-filepub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.Dir = dir
+	p := NewFilePublisher().(*FilePublisher)
+	p.Config("test", &PubConfig{Topic: "messages", File: conf})
+	return p, dir
+}
+
+func TestFilePublisher_Write(t *testing.T) {
+	p, dir := newTestFilePublisher(t, FileConfig{Fsync: "always"})
+	defer os.RemoveAll(dir)
+
+	in := make(chan *Message)
+	done := make(chan bool)
+	go func() {
+		p.Start(in)
+		done <- true
+	}()
+	in <- NewMessage("one")
+	in <- NewMessage("two")
+	close(in)
+	<-done
+	assert.Nil(t, p.Close())
+	assert.Nil(t, p.Close(), "Closing twice must not panic")
+
+	f, err := os.Open(filepath.Join(dir, "messages"+fileExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var bodies []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var m Message
+		assert.Nil(t, json.Unmarshal(scanner.Bytes(), &m), "Each line must be a JSON envelope")
+		bodies = append(bodies, m.Body)
+	}
+	assert.Equal(t, []string{"one", "two"}, bodies)
+}
+
+func TestFilePublisher_Roll(t *testing.T) {
+	p, dir := newTestFilePublisher(t, FileConfig{Gzip: true, Fsync: "never"})
+	defer os.RemoveAll(dir)
+
+	// a single message bigger than the limit lands in its own file
+	p.conf.MaxSizeMB = 1
+	body := strings.Repeat("x", 1<<20)
+	assert.Nil(t, p.write(NewMessage(body)))
+	assert.Nil(t, p.write(NewMessage(body)))
+	assert.Nil(t, p.Close())
+
+	rolled, err := filepath.Glob(filepath.Join(dir, "messages-*"+fileExt+".gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, len(rolled), "Full file must be rolled over and compressed")
+
+	plain, _ := filepath.Glob(filepath.Join(dir, "messages-*"+fileExt))
+	assert.Equal(t, 0, len(plain), "Compressed files must replace the rolled ones")
+
+	f, err := os.Open(rolled[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m Message
+	assert.Nil(t, json.NewDecoder(zr).Decode(&m))
+	assert.Equal(t, body, m.Body)
+}
@@ -16,10 +16,9 @@
 package main
 
 import (
-	"fmt"
 	"io"
-	"log"
 	"sync/atomic"
+	"time"
 
 	"code.google.com/p/go.net/websocket"
 )
@@ -30,7 +29,6 @@ const (
 
 var (
 	maxClientID int64
-	maxMsgID    int64
 	msg         = websocket.Message
 )
 
@@ -39,15 +37,53 @@ type handler struct {
 	ws     *websocket.Conn
 	server *broker
 	ch     chan *interface{}
-	sender chan *Message
-}
+	outbox *outbox
+	doneCh chan bool
+	acks   bool
+	device string
+	tenant string
+	auth   string
+	log    *Logger
+	remote string
+	since  time.Time
+
+	// activity of the connection, lastSeen is the last frame including
+	// pongs and lastMessage the last message, both in unix nanoseconds
+	received    int64
+	bytes       int64
+	lastSeen    int64
+	lastMessage int64
+	closing     int32
+
+	// keepalive of the connection
+	pingEvery   time.Duration
+	readTimeout time.Duration
+	idleTimeout time.Duration
+
+	// closeCode is the code the gateway closed the connection with
+	closeCode int32
+
+	// will is published when the connection ends abruptly, it is sent
+	// as the first frame when awaitWill is set
+	will      string
+	awaitWill bool
 
-// Publisher defines the implementation for publisher
-type Publisher interface {
-	Config(clientID string, args *PubConfig)
-	Start(in <-chan *Message)
+	// delivery results of the messages sent by this connection
+	published int64
+	failed    int64
+	hooks     []func(msg *Message, err error)
+	throttled int64
+
+	// limits of the connection, the device and its daily quota
+	rates      *RateLimits
+	rate       *rateLimit
+	deviceRate *rateLimit
+	quota      *quota
 }
 
+// closeFrame queued to the client closes the connection with its code
+type closeFrame int
+
 func newClient(ws *websocket.Conn, s *broker) *handler {
 	if ws == nil {
 		panic("ws cannot be nil")
@@ -55,50 +91,281 @@ func newClient(ws *websocket.Conn, s *broker) *handler {
 	if s == nil {
 		panic("server cannot be nil")
 	}
-	atomic.AddInt64(&maxClientID, 1)
-	ch := make(chan *interface{}, channelBufSize)
+	id := atomic.AddInt64(&maxClientID, 1)
+	ch := make(chan *interface{}, args.Server.Pipeline.SendBuffer)
+	device := deviceID(s.authVal, ws.Request())
 
 	h := &handler{
-		id:     maxClientID,
+		id:     id,
 		ws:     ws,
 		server: s,
 		ch:     ch,
-		sender: make(chan *Message, 1),
+		outbox: s.pipeline.newOutbox(),
+		doneCh: make(chan bool),
+		acks:   wantsAcks(ws.Request()),
+		device: device,
+		tenant: tenantID(s.authVal, ws.Request()),
+		auth:   args.Server.AuthMethod,
+		log:    logger.With("conn", id).With("device", device),
+		remote: ws.Request().RemoteAddr,
+		since:  time.Now().UTC(),
+	}
+	h.lastSeen = h.since.UnixNano()
+	h.lastMessage = h.lastSeen
+	h.pingEvery = time.Duration(args.Server.PingInterval) * time.Second
+	h.readTimeout = time.Duration(args.Server.ReadTimeout) * time.Second
+	h.idleTimeout = time.Duration(args.Server.IdleTimeout) * time.Second
+	h.rates = rates
+	h.rate, h.deviceRate, h.quota = h.rates.attach(h)
+	if wills != nil {
+		if err := h.setWill(ws.Request().Header.Get(willHeader)); err != nil {
+			h.log.Warnf("last will rejected: %v", err)
+		}
+		h.awaitWill = wantsWillFrame(ws.Request())
+	}
+
+	if args.Pub.Breaker.Enabled {
+		h.onDelivered(h.backoff)
 	}
 
-	go produce(h.sender)
 	return h
 }
 
+// write queues the frame to the client, a client not reading its frames
+// fast enough is cut off as there is no room left for a close frame either
 func (c *handler) write(msg *interface{}) {
 	select {
 	case c.ch <- msg:
 	default:
-		c.server.del(c)
-		err := fmt.Errorf("handler %d is disconnected on %s",
-			c.id, args.Index)
-		c.server.err(err)
+		if atomic.CompareAndSwapInt32(&c.closing, 0, 1) {
+			c.log.Warnf("disconnecting: %d frames are not read", cap(c.ch))
+			c.ws.Close()
+		}
 	}
 }
 
 func (c *handler) conn() *websocket.Conn { return c.ws }
-func (c *handler) listen()               { c.listenRead() }
-func (c *handler) listenRead() {
+
+// disconnect closes the connection with the code, clients which do not
+// close their side within grace are cut off
+func (c *handler) disconnect(code int, grace time.Duration) {
+	atomic.StoreInt32(&c.closing, 1)
+	c.close(code)
+	time.AfterFunc(grace, func() {
+		select {
+		case <-c.doneCh:
+		default:
+			c.ws.Close()
+		}
+	})
+}
+
+// deliver queues the frame to the client unless it is disconnected or
+// too far behind, it tells whether the frame was queued
+func (c *handler) deliver(frame interface{}) bool {
+	select {
+	case <-c.doneCh:
+		return false
+	default:
+	}
+	select {
+	case c.ch <- &frame:
+		return true
+	default:
+		return false
+	}
+}
+
+// close asks the client to close the connection with the code
+func (c *handler) close(code int) {
+	select {
+	case <-c.doneCh:
+		// already disconnected
+	default:
+		var frame interface{} = closeFrame(code)
+		c.write(&frame)
+	}
+}
+func (c *handler) listen() {
+	go c.listenWrite()
+	err := c.listenRead()
+	close(c.doneCh)
+	c.rates.detach(c)
+	// connections the gateway cut off (admin disconnect, shutdown, a
+	// client not reading its frames) did not end abruptly on their side
+	if err != io.EOF && err != errIdleTimeout && atomic.LoadInt32(&c.closing) == 0 {
+		c.publishWill()
+	}
+	presence.disconnected(c, err)
+}
+
+func (c *handler) listenWrite() {
+	ticker := time.NewTicker(c.pingEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.ping()
+		case m := <-c.ch:
+			if code, ok := (*m).(closeFrame); ok {
+				if err := c.ws.WriteClose(int(code)); err != nil {
+					c.server.err(err)
+				} else {
+					atomic.CompareAndSwapInt32(&c.closeCode, 0, int32(code))
+				}
+				continue
+			}
+			if err := websocket.JSON.Send(c.ws, *m); err != nil {
+				c.server.err(err)
+			}
+		case <-c.doneCh:
+			return
+		}
+	}
+}
+
+// acknowledge returns the delivery callback replying to the client
+// with an ack or nack for the message it sent as ref
+func (c *handler) acknowledge(ref string) func(err error) {
+	return func(err error) {
+		var reply interface{} = newAckReply(ref, err)
+		select {
+		case <-c.doneCh:
+			// nobody is listening anymore
+		default:
+			c.write(&reply)
+		}
+	}
+}
+
+// newMessage wraps the body sent by the client into a message carrying
+// the identity of the client, its delivery result is routed back to this
+// connection and to the client through ack when given
+func (c *handler) newMessage(body string, ack func(err error)) *Message {
+	m := NewMessage(body)
+	m.Device = c.device
+	m.Auth = c.auth
+	atomic.AddInt64(&inflight, 1)
+	m.delivered = func(err error) {
+		atomic.AddInt64(&inflight, -1)
+		c.result(m, err)
+		if ack != nil {
+			ack(err)
+		}
+	}
+	return m
+}
+
+// onDelivered registers a callback for the delivery results of the messages
+// sent by this connection, callbacks are registered before it listens
+func (c *handler) onDelivered(f func(msg *Message, err error)) {
+	c.hooks = append(c.hooks, f)
+}
+
+// result counts the delivery result and passes it to the callbacks
+func (c *handler) result(msg *Message, err error) {
+	if err != nil {
+		atomic.AddInt64(&c.failed, 1)
+	} else {
+		atomic.AddInt64(&c.published, 1)
+	}
+	for _, f := range c.hooks {
+		f(msg, err)
+	}
+}
+
+// counters returns the number of messages published and failed so far
+func (c *handler) counters() (published, failed int64) {
+	return atomic.LoadInt64(&c.published), atomic.LoadInt64(&c.failed)
+}
+
+// receive turns the frame sent by the client into a message
+// and queues it to the publisher
+func (c *handler) receive(frame string) {
+	span := startSpan("gateway.receive", spanServer, nil)
+	span.SetAttr("gateway.conn", c.id)
+	span.SetAttr("device.id", c.device)
+	span.SetAttr("messaging.message.body.size", len(frame))
+	defer span.End(nil)
+
+	transform := startSpan("gateway.transform", spanInternal, span)
+	body, ack := frame, (func(error))(nil)
+	if c.acks {
+		ref, b, err := parseAckFrame(frame)
+		if err != nil {
+			transform.End(err)
+			span.End(err)
+			invalid := c.newMessage(frame, c.acknowledge(ref))
+			c.log.Message(invalid).Warnf("invalid frame: %v", err)
+			deadLetters.Send(invalid, stageValidation, err, 1)
+			invalid.Delivered(err)
+			return
+		}
+		body, ack = b, c.acknowledge(ref)
+	}
+	m := c.newMessage(body, ack)
+	transform.End(nil)
+	c.send(m, span)
+}
+
+// send queues the message to the publisher, the publish span lasts
+// until the backend confirmed or failed the delivery
+func (c *handler) send(m *Message, parent *Span) {
+	span := startSpan("gateway.publish", spanProducer, parent)
+	span.SetAttr("messaging.system", args.Pub.Backend)
+	span.SetAttr("messaging.destination.name", args.Pub.Topic)
+	span.SetAttr("messaging.message.id", m.ID)
+	m.Trace = span.Traceparent()
+	delivered := m.delivered
+	m.delivered = func(err error) {
+		span.End(err)
+		delivered(err)
+	}
+
+	c.log.Message(m).Debugf("queued > %s", logBody(m.Body))
+	if err := c.server.pipeline.push(c.outbox, m); err != nil {
+		c.log.Warnf("disconnecting: %v", err)
+		c.disconnect(closeTryAgainLater, disconnectGrace)
+	}
+}
+
+// listenRead receives the frames of the client until it disconnects or
+// is not heard from in time, it returns the error the connection ended with
+func (c *handler) listenRead() error {
 	for {
 		var m string
-		err := msg.Receive(c.ws, &m)
-		if err == io.EOF {
+		if err := c.readMessage(&m); err != nil {
 			c.server.del(c)
-			return
-		} else if err != nil {
-			c.server.err(err)
+			switch {
+			case err == io.EOF:
+			case err == errMessageTooBig:
+				c.log.Warnf("disconnected: %v", err)
+				if c.ws.WriteClose(closeMessageTooBig) == nil {
+					atomic.CompareAndSwapInt32(&c.closeCode, 0, closeMessageTooBig)
+				}
+			case atomic.LoadInt32(&c.closing) == 1:
+				// the connection was cut off
+			case isTimeout(err):
+				err = c.timeout()
+				c.log.Infof("disconnected: %v", err)
+			default:
+				c.server.err(err)
+			}
+			return err
 		} else {
-			if args.Trace {
-				atomic.AddInt64(&maxMsgID, 1)
-				log.Printf("handler[%d] queued > msg[%d]:%s",
-					c.id, maxMsgID, m)
+			atomic.AddInt64(&c.received, 1)
+			atomic.AddInt64(&c.bytes, int64(len(m)))
+			c.seen(true)
+			messagesReceived.with().inc()
+			bytesReceived.with().add(float64(len(m)))
+			if c.awaitWill {
+				c.awaitWill = false
+				c.registerWill(m)
+				continue
+			}
+			if c.admit(m) {
+				c.receive(m)
 			}
-			c.sender <- NewMessage(m)
 		}
 	}
 }
@@ -0,0 +1,141 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"code.google.com/p/go.net/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// ackPublisher fails the messages with the "fail" body and delivers the rest
+type ackPublisher struct{}
+
+func (p *ackPublisher) Config(clientID string, args *PubConfig) {}
+func (p *ackPublisher) Start(in <-chan *Message) {
+	for msg := range in {
+		if msg.Body == "fail" {
+			msg.Delivered(errors.New("boom"))
+		} else {
+			msg.Delivered(nil)
+		}
+	}
+}
+
+// newTestServer serves the handlers of a broker tracking its clients
+// without registering the gateway routes
+func newTestServer(t testing.TB) (*httptest.Server, *broker) {
+	return serveTestBroker(t, newBroker())
+}
+
+// serveTestBroker serves the handlers of the broker
+func serveTestBroker(t testing.TB, b *broker) (*httptest.Server, *broker) {
+	go func() {
+		for {
+			select {
+			case c := <-b.addCh:
+				b.clients[c.id] = c
+			case c := <-b.delCh:
+				delete(b.clients, c.id)
+			case <-b.errCh:
+			case ch := <-b.listCh:
+				clients := make([]*handler, 0, len(b.clients))
+				for _, c := range b.clients {
+					clients = append(clients, c)
+				}
+				ch <- clients
+			}
+		}
+	}()
+	return httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		h := newClient(ws, b)
+		b.add(h)
+		presence.connected(h)
+		h.listen()
+	})), b
+}
+
+func dialTestServer(t testing.TB, ts *httptest.Server, path string) *websocket.Conn {
+	ws, err := websocket.Dial(strings.Replace(ts.URL, "http", "ws", 1)+path, "", "http://localhost/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ws
+}
+
+func TestHandler_Acks(t *testing.T) {
+	pub = &ackPublisher{}
+	ts, _ := newTestServer(t)
+	defer ts.Close()
+
+	ws := dialTestServer(t, ts, "/?ack=true")
+	defer ws.Close()
+	ws.SetDeadline(time.Now().Add(5 * time.Second))
+
+	frames := []struct {
+		frame string
+		reply ackReply
+	}{
+		{`{"id": "1", "body": {"temp": 21}}`, ackReply{Type: "ack", ID: "1"}},
+		{`{"id": "2", "body": "fail"}`, ackReply{Type: "nack", ID: "2", Reason: "boom"}},
+		{`not a frame`, ackReply{Type: "nack", Reason: errInvalidAckFrame.Error()}},
+	}
+	for _, f := range frames {
+		assert.Nil(t, websocket.Message.Send(ws, f.frame))
+		var reply ackReply
+		assert.Nil(t, websocket.JSON.Receive(ws, &reply))
+		assert.Equal(t, f.reply, reply)
+	}
+}
+
+func TestParseAckFrame(t *testing.T) {
+	ref, body, err := parseAckFrame(`{"id": "a", "body": "text"}`)
+	assert.Nil(t, err)
+	assert.Equal(t, "a", ref)
+	assert.Equal(t, "text", body, "String bodies must be unquoted")
+
+	_, body, err = parseAckFrame(`{"id": "b", "body": {"k": [1, 2]}}`)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"k": [1, 2]}`, body, "JSON bodies must be kept as is")
+
+	ref, _, err = parseAckFrame(`{"id": "c"}`)
+	assert.Equal(t, errInvalidAckFrame, err, "Frames must have a body")
+	assert.Equal(t, "c", ref)
+}
+
+func TestHandler_Results(t *testing.T) {
+	h := &handler{device: "dev1", auth: "jwt"}
+	var results []error
+	h.onDelivered(func(msg *Message, err error) {
+		assert.Equal(t, "dev1", msg.Device, "Results must be routed with their message")
+		results = append(results, err)
+	})
+
+	var acked error
+	h.newMessage("one", nil).Delivered(nil)
+	h.newMessage("two", func(err error) { acked = err }).Delivered(errors.New("boom"))
+
+	published, failed := h.counters()
+	assert.Equal(t, int64(1), published)
+	assert.Equal(t, int64(1), failed)
+	assert.Equal(t, []error{nil, errors.New("boom")}, results)
+	assert.Equal(t, errors.New("boom"), acked, "Clients waiting for acks must get the result too")
+}
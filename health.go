@@ -0,0 +1,113 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const (
+	statusOK          = "ok"
+	statusUnavailable = "unavailable"
+)
+
+// healthChecker is implemented by the publishers able to report the state
+// of their backends, each backend is a component named after it
+type healthChecker interface {
+	health(status map[string]error)
+}
+
+// checkHealth adds the components of the publisher to the status
+func checkHealth(p Publisher, status map[string]error) {
+	if c, ok := p.(healthChecker); ok {
+		c.health(status)
+	}
+}
+
+// componentStatus is the state of a single component
+type componentStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// healthStatus is the state of the gateway and its components
+type healthStatus struct {
+	Status     string                     `json:"status"`
+	Components map[string]componentStatus `json:"components,omitempty"`
+}
+
+// readiness checks the components the gateway needs to take messages:
+// the backends, the spool and, with JWT authentication, the key service
+func readiness() *healthStatus {
+	status := make(map[string]error)
+	checkHealth(pub, status)
+	if args.Server.AuthMethod == "jwt" {
+		status["keys"] = checkDeviceKeys(args.Server.DeviceKeysURI)
+	}
+
+	h := &healthStatus{Status: statusOK, Components: make(map[string]componentStatus)}
+	for name, err := range status {
+		c := componentStatus{Status: statusOK}
+		if err != nil {
+			c = componentStatus{Status: statusUnavailable, Error: err.Error()}
+			h.Status = statusUnavailable
+		}
+		h.Components[name] = c
+	}
+	return h
+}
+
+// checkDeviceKeys tells whether the device keys API responds at all,
+// any HTTP response counts as the service being reachable
+func checkDeviceKeys(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Head(fmt.Sprintf("%s://%s/", u.Scheme, u.Host))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func showHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
+	writeHealth(w, &healthStatus{Status: statusOK})
+}
+
+func showReadiness(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
+	writeHealth(w, readiness())
+}
+
+func writeHealth(w http.ResponseWriter, h *healthStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	if h.Status != statusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(h)
+}
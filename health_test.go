@@ -0,0 +1,121 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+// healthPublisher reports a fixed state of its backend
+type healthPublisher struct {
+	ackPublisher
+	err error
+}
+
+func (p *healthPublisher) health(status map[string]error) {
+	status["backend"] = p.err
+}
+
+func getHealth(t *testing.T, handler http.HandlerFunc, path string) (int, *healthStatus) {
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", path, nil))
+	h := &healthStatus{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), h))
+	return w.Code, h
+}
+
+func TestShowHealth(t *testing.T) {
+	code, h := getHealth(t, showHealth, "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, statusOK, h.Status)
+}
+
+func TestShowReadiness(t *testing.T) {
+	defer func(p Publisher) { pub = p }(pub)
+
+	pub = &healthPublisher{}
+	code, h := getHealth(t, showReadiness, "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]componentStatus{"backend": {Status: statusOK}}, h.Components)
+
+	pub = NewBreakerPublisher(&FanoutPublisher{targets: []*fanoutTarget{
+		{name: "down", required: true, pub: &healthPublisher{err: errors.New("no brokers")}},
+		{name: "optional", pub: &RedisPublisher{}},
+	}})
+	code, h = getHealth(t, showReadiness, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code, "Instances with a dead backend must not be ready")
+	assert.Equal(t, statusUnavailable, h.Status)
+	assert.Equal(t, map[string]componentStatus{
+		"backend": {Status: statusUnavailable, Error: "no brokers"},
+	}, h.Components, "Best-effort backends must not affect the readiness")
+}
+
+func TestCheckDeviceKeys(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	assert.Nil(t, checkDeviceKeys(ts.URL+"/devices/:device_id/key"), "Any response means the service is reachable")
+	ts.Close()
+	assert.NotNil(t, checkDeviceKeys(ts.URL+"/devices/:device_id/key"))
+}
+
+func TestPublisherHealth(t *testing.T) {
+	status := make(map[string]error)
+	checkHealth(&RedisPublisher{}, status)
+	checkHealth(&KafkaPublisher{}, status)
+	assert.NotNil(t, status["redis"], "Disconnected Redis publisher must be unavailable")
+	assert.NotNil(t, status["kafka"], "Kafka publisher without a client must be unavailable")
+}
+
+// slowClient is a Kafka client without brokers whose metadata refresh
+// takes its time
+type slowClient struct {
+	sarama.Client
+	refreshed chan bool
+}
+
+func (c *slowClient) Closed() bool              { return false }
+func (c *slowClient) Brokers() []*sarama.Broker { return nil }
+func (c *slowClient) RefreshMetadata(topics ...string) error {
+	time.Sleep(300 * time.Millisecond)
+	c.refreshed <- true
+	return sarama.ErrOutOfBrokers
+}
+
+func TestKafkaPublisher_Health(t *testing.T) {
+	client := &slowClient{refreshed: make(chan bool, 10)}
+	p := &KafkaPublisher{client: client}
+
+	start := time.Now()
+	status := make(map[string]error)
+	checkHealth(p, status)
+	assert.Equal(t, sarama.ErrOutOfBrokers, status["kafka"])
+	checkHealth(p, status)
+	assert.True(t, time.Since(start) < 200*time.Millisecond, "Readiness must not wait for the metadata refresh")
+	<-client.refreshed
+	assert.Len(t, client.refreshed, 0, "Only one metadata refresh must run at a time")
+
+	atomic.StoreInt64(&p.delivered, time.Now().UnixNano())
+	checkHealth(p, status)
+	assert.Nil(t, status["kafka"], "Producer delivering messages must be ready")
+}
@@ -0,0 +1,350 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/eapache/go-resiliency/breaker"
+)
+
+const (
+	defaultIngestPath      = "/v1/messages"
+	defaultIngestMaxBatch  = 1000
+	defaultIngestMaxBodyKB = 4096
+	defaultIngestTimeout   = 30
+
+	contentTypeJSON   = "application/json"
+	contentTypeNDJSON = "application/x-ndjson"
+)
+
+var (
+	errEmptyBatch       = errors.New("no messages in the request")
+	errInvalidBatch     = errors.New("invalid JSON, expected a message, an array of messages or NDJSON")
+	errInvalidMessage   = errors.New("invalid JSON message")
+	errBatchTooLarge    = errors.New("too many messages in the request")
+	errBodyTooLarge     = errors.New("request body too large")
+	errUnsupportedType  = errors.New("unsupported content type, expected application/json or application/x-ndjson")
+	errDeliveryTimeout  = errors.New("delivery not confirmed in time")
+	errMessageTooLarge  = errors.New("message too large")
+	errIngestNotAllowed = errors.New("method not allowed")
+
+	ingestRequests = newCounter("gateway_ingest_requests_total",
+		"HTTP ingestion requests by response status.", "status")
+)
+
+// ingestItem is a message of an ingestion request or why it is invalid
+type ingestItem struct {
+	body string
+	err  error
+}
+
+// ingestResult is the delivery result of a message of an ingestion request,
+// status is the HTTP status of the message alone
+type ingestResult struct {
+	Index      int    `json:"index"`
+	ID         string `json:"id,omitempty"`
+	Status     int    `json:"status"`
+	Error      string `json:"error,omitempty"`
+	RetryAfter int    `json:"retry_after,omitempty"`
+}
+
+// ingestResponse reports the delivery of all of the messages of a request
+type ingestResponse struct {
+	Accepted int            `json:"accepted"`
+	Failed   int            `json:"failed"`
+	Results  []ingestResult `json:"results"`
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// parseBatch splits the request body into its messages, either a single
+// JSON message, a JSON array of messages or NDJSON with a message per line
+func parseBatch(contentType string, r io.Reader, maxMessage int64) ([]ingestItem, error) {
+	mediaType := contentTypeJSON
+	if len(contentType) > 0 {
+		var err error
+		if mediaType, _, err = mime.ParseMediaType(contentType); err != nil {
+			return nil, errUnsupportedType
+		}
+	}
+
+	var items []ingestItem
+	switch mediaType {
+	case contentTypeNDJSON:
+		// the buffer grows up to the largest message
+		size := int(maxMessage) + 1
+		if size > 4096 {
+			size = 4096
+		}
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, size), int(maxMessage)+1)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			switch {
+			case len(line) == 0:
+			case !json.Valid(line):
+				items = append(items, ingestItem{body: string(line), err: errInvalidMessage})
+			default:
+				items = append(items, ingestItem{body: rawBody(line)})
+			}
+		}
+		if err := scanner.Err(); err == bufio.ErrTooLong {
+			return nil, errMessageTooLarge
+		} else if err != nil {
+			return nil, err
+		}
+	case contentTypeJSON:
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		data = bytes.TrimSpace(data)
+		if len(data) > 0 && data[0] == '[' {
+			var raws []json.RawMessage
+			if err := json.Unmarshal(data, &raws); err != nil {
+				return nil, errInvalidBatch
+			}
+			for _, raw := range raws {
+				items = append(items, ingestItem{body: rawBody(raw)})
+			}
+		} else if len(data) > 0 {
+			if !json.Valid(data) {
+				return nil, errInvalidBatch
+			}
+			items = append(items, ingestItem{body: rawBody(data)})
+		}
+	default:
+		return nil, errUnsupportedType
+	}
+	return items, nil
+}
+
+// ingestStatus returns the HTTP status of a message failing with err
+func ingestStatus(err error) int {
+	switch err {
+	case nil:
+		return http.StatusAccepted
+	case errInvalidMessage:
+		return http.StatusBadRequest
+	case errMessageTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case errDeliveryTimeout:
+		return http.StatusGatewayTimeout
+	case errRateLimited, errQuotaExceeded:
+		return http.StatusTooManyRequests
+	case errBufferFull, errPipelineClosed, breaker.ErrBreakerOpen:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadGateway
+}
+
+// requestStatus returns the HTTP status of a request rejected as a whole
+func requestStatus(err error) int {
+	switch err {
+	case errIngestNotAllowed:
+		return http.StatusMethodNotAllowed
+	case errInvalidToken:
+		return http.StatusUnauthorized
+	case errUnsupportedType:
+		return http.StatusUnsupportedMediaType
+	case errBodyTooLarge, errBatchTooLarge, errMessageTooLarge:
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// onIngest publishes the messages posted by a device, the response waits
+// for their delivery: 202 when the backend took all of them, 207 along
+// with the status of each message otherwise
+func (s *broker) onIngest(w http.ResponseWriter, req *http.Request) {
+	span := startSpan("gateway.ingest", spanServer, remoteSpan(req.Header.Get(headerTraceparent)))
+	span.SetAttr("http.target", req.URL.Path)
+	span.SetAttr("net.peer.name", req.RemoteAddr)
+	log := logger.With("remote", req.RemoteAddr)
+
+	items, err := s.readBatch(req, span)
+	if err != nil {
+		status := requestStatus(err)
+		log.Warnf("ingestion rejected: %v", err)
+		ingestRequests.with(strconv.Itoa(status)).inc()
+		span.End(err)
+		if status == http.StatusUnauthorized {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gateway"`)
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	device := deviceID(s.authVal, req)
+	resp := s.ingest(items, device, tenantID(s.authVal, req), span, time.Duration(args.Server.Ingest.Timeout)*time.Second)
+	status := http.StatusAccepted
+	if resp.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	log.With("device", device).Debugf("ingested %d messages, %d failed", resp.Accepted, resp.Failed)
+	ingestRequests.with(strconv.Itoa(status)).inc()
+	span.End(nil)
+	writeJSONResponse(w, status, resp)
+}
+
+// readBatch authenticates the request and parses its messages
+func (s *broker) readBatch(req *http.Request, span *Span) ([]ingestItem, error) {
+	if req.Method != "POST" {
+		return nil, errIngestNotAllowed
+	}
+
+	auth := startSpan("gateway.auth", spanInternal, span)
+	auth.SetAttr("gateway.auth.method", args.Server.AuthMethod)
+	if !s.authVal.Validate(req.WithContext(contextWithSpan(req.Context(), auth))) {
+		auth.End(errInvalidToken)
+		authFailures.with(args.Server.AuthMethod, authFailureReason(req)).inc()
+		return nil, errInvalidToken
+	}
+	auth.End(nil)
+
+	maxBody := int64(args.Server.Ingest.MaxBodyKB) * 1024
+	body := &countingReader{r: io.LimitReader(req.Body, maxBody+1)}
+	items, err := parseBatch(req.Header.Get("Content-Type"), body, s.maxMessage)
+	switch {
+	case body.n > maxBody:
+		return nil, errBodyTooLarge
+	case err != nil:
+		return nil, err
+	case len(items) == 0:
+		return nil, errEmptyBatch
+	case len(items) > args.Server.Ingest.MaxBatch:
+		return nil, errBatchTooLarge
+	}
+	return items, nil
+}
+
+// ingest publishes the messages through the pipeline and waits up to
+// timeout for their delivery, the messages over the rate limits or the
+// daily quota of the device are rejected
+func (s *broker) ingest(items []ingestItem, device, tenant string, parent *Span, timeout time.Duration) *ingestResponse {
+	type result struct {
+		index int
+		err   error
+	}
+	results := make(chan result, len(items))
+	resp := &ingestResponse{Results: make([]ingestResult, len(items))}
+	o := s.pipeline.newOutbox()
+
+	// the request is limited by the limits of the device, a request
+	// is not a connection so the connection limits do not apply
+	c := &handler{device: device, tenant: tenant, rates: rates}
+	_, c.deviceRate, c.quota = rates.attach(c)
+	defer rates.detach(c)
+
+	// cut is the error the request was cut off with, the messages
+	// following it are rejected as a connection would be disconnected
+	var cut error
+	for i, item := range items {
+		resp.Results[i].Index = i
+		messagesReceived.with().inc()
+		bytesReceived.with().add(float64(len(item.body)))
+		m := NewMessage(item.body)
+		m.Device = device
+		m.Auth = args.Server.AuthMethod
+		resp.Results[i].ID = m.ID
+		if cut != nil {
+			results <- result{i, cut}
+			continue
+		}
+		if item.err == nil && int64(len(item.body)) > s.maxMessage {
+			item.err = errMessageTooLarge
+		}
+		if item.err != nil {
+			deadLetters.Send(m, stageValidation, item.err, 1)
+			results <- result{i, item.err}
+			continue
+		}
+		if retry, err := c.limit(len(item.body), rateDrop); err != nil {
+			resp.Results[i].RetryAfter = int((retry + time.Second - 1) / time.Second)
+			results <- result{i, err}
+			continue
+		}
+
+		span := startSpan("gateway.publish", spanProducer, parent)
+		span.SetAttr("messaging.system", args.Pub.Backend)
+		span.SetAttr("messaging.destination.name", args.Pub.Topic)
+		span.SetAttr("messaging.message.id", m.ID)
+		m.Trace = span.Traceparent()
+		atomic.AddInt64(&inflight, 1)
+		i := i
+		m.delivered = func(err error) {
+			atomic.AddInt64(&inflight, -1)
+			span.End(err)
+			results <- result{i, err}
+		}
+		if err := s.pipeline.push(o, m); err != nil {
+			// the message was dropped and is reported as such
+			cut = err
+		}
+	}
+
+	done := make([]bool, len(items))
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for n := 0; n < len(items); n++ {
+		var r result
+		select {
+		case r = <-results:
+		case <-timer.C:
+			// the messages may still be delivered, the client cannot tell
+			for i := range done {
+				if !done[i] {
+					resp.setResult(i, errDeliveryTimeout)
+				}
+			}
+			return resp
+		}
+		done[r.index] = true
+		resp.setResult(r.index, r.err)
+	}
+	return resp
+}
+
+func (r *ingestResponse) setResult(i int, err error) {
+	r.Results[i].Status = ingestStatus(err)
+	if err != nil {
+		r.Results[i].Error = err.Error()
+		r.Failed++
+	} else {
+		r.Accepted++
+	}
+}
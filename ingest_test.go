@@ -0,0 +1,218 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rejectAuth admits no request
+type rejectAuth struct{}
+
+func (a rejectAuth) Validate(req *http.Request) bool { return false }
+
+func TestParseBatch(t *testing.T) {
+	items, err := parseBatch("", strings.NewReader(` {"temp": 21} `), 1024)
+	assert.Nil(t, err)
+	assert.Equal(t, []ingestItem{{body: `{"temp": 21}`}}, items, "Single messages must be kept as is")
+
+	items, err = parseBatch("application/json; charset=utf-8", strings.NewReader(`["text", {"temp": 21}, 3]`), 1024)
+	assert.Nil(t, err)
+	assert.Equal(t, []ingestItem{{body: "text"}, {body: `{"temp": 21}`}, {body: "3"}}, items,
+		"String messages must be unquoted")
+
+	items, err = parseBatch(contentTypeNDJSON, strings.NewReader("{\"temp\": 21}\n\n\"text\"\nnot json\n"), 1024)
+	assert.Nil(t, err)
+	assert.Equal(t, []ingestItem{{body: `{"temp": 21}`}, {body: "text"}, {body: "not json", err: errInvalidMessage}}, items,
+		"Invalid lines must only fail on their own")
+
+	for _, test := range []struct {
+		contentType, body string
+		err               error
+	}{
+		{"", `["unterminated"`, errInvalidBatch},
+		{"", `not json`, errInvalidBatch},
+		{"text/plain", `text`, errUnsupportedType},
+		{contentTypeNDJSON, strings.Repeat("1", 2048), errMessageTooLarge},
+	} {
+		_, err := parseBatch(test.contentType, strings.NewReader(test.body), 1024)
+		assert.Equal(t, test.err, err, test.contentType)
+	}
+
+	items, err = parseBatch("", strings.NewReader(" "), 1024)
+	assert.Nil(t, err)
+	assert.Empty(t, items)
+}
+
+func TestBroker_Ingest(t *testing.T) {
+	defer func(p Publisher) { pub = p }(pub)
+	pub = &ackPublisher{}
+	b := newBroker()
+	b.authVal = deviceAuth{}
+	ts := httptest.NewServer(http.HandlerFunc(b.onIngest))
+	defer ts.Close()
+
+	post := func(path, contentType, body string) (int, *ingestResponse) {
+		resp, err := http.Post(ts.URL+path, contentType, strings.NewReader(body))
+		if !assert.Nil(t, err) {
+			return 0, nil
+		}
+		defer resp.Body.Close()
+		var r ingestResponse
+		json.NewDecoder(resp.Body).Decode(&r)
+		return resp.StatusCode, &r
+	}
+
+	status, resp := post("/v1/messages?device=dev1", "application/json", `["one", {"temp": 21}]`)
+	assert.Equal(t, http.StatusAccepted, status)
+	assert.Equal(t, 2, resp.Accepted)
+	if assert.Len(t, resp.Results, 2) {
+		assert.Equal(t, http.StatusAccepted, resp.Results[1].Status)
+		assert.Equal(t, 1, resp.Results[1].Index)
+		assert.NotEmpty(t, resp.Results[1].ID)
+	}
+
+	status, resp = post("/v1/messages", contentTypeNDJSON, "\"ok\"\n\"fail\"\n{broken\n")
+	assert.Equal(t, http.StatusMultiStatus, status, "Partly failed batches must report each message")
+	assert.Equal(t, 1, resp.Accepted)
+	assert.Equal(t, 2, resp.Failed)
+	if assert.Len(t, resp.Results, 3) {
+		assert.Equal(t, http.StatusAccepted, resp.Results[0].Status)
+		assert.Equal(t, ingestResult{Index: 1, ID: resp.Results[1].ID, Status: http.StatusBadGateway, Error: "boom"}, resp.Results[1])
+		assert.Equal(t, http.StatusBadRequest, resp.Results[2].Status)
+	}
+
+	status, _ = post("/v1/messages", "application/json", " ")
+	assert.Equal(t, http.StatusBadRequest, status, "Empty requests must be rejected")
+	status, _ = post("/v1/messages", "text/csv", "a,b")
+	assert.Equal(t, http.StatusUnsupportedMediaType, status)
+	defer func(n int) { args.Server.Ingest.MaxBatch = n }(args.Server.Ingest.MaxBatch)
+	args.Server.Ingest.MaxBatch = 2
+	status, _ = post("/v1/messages", "application/json", `[1, 2, 3]`)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, status)
+
+	r, err := http.Get(ts.URL + "/v1/messages")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusMethodNotAllowed, r.StatusCode)
+	r.Body.Close()
+
+	b.authVal = rejectAuth{}
+	status, _ = post("/v1/messages", "application/json", `"one"`)
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
+
+func TestBroker_IngestRateLimit(t *testing.T) {
+	defer func(p Publisher) { pub = p }(pub)
+	pub = &ackPublisher{}
+	rates = newRateLimits(&RateLimitConfig{DeviceMessages: 2, QuotaBy: quotaByDevice, Action: rateDelay})
+	defer func() { rates = nil }()
+	b := newBroker()
+	items := []ingestItem{{body: "1"}, {body: "2"}, {body: "3"}}
+
+	resp := b.ingest(items, "dev1", "", nil, time.Second)
+	assert.Equal(t, 2, resp.Accepted)
+	assert.Equal(t, ingestResult{Index: 2, ID: resp.Results[2].ID, Status: http.StatusTooManyRequests,
+		Error: errRateLimited.Error(), RetryAfter: 1}, resp.Results[2], "Messages over the rate limit must be rejected")
+
+	// requests are not limited as connections
+	rates = newRateLimits(&RateLimitConfig{Messages: 1, QuotaBy: quotaByDevice})
+	resp = b.ingest(items, "dev1", "", nil, time.Second)
+	assert.Equal(t, 3, resp.Accepted, "Connection limits must not apply to requests")
+
+	rates = newRateLimits(&RateLimitConfig{DailyMessages: 3, QuotaBy: quotaByDevice})
+	b.ingest(items[:2], "dev1", "", nil, time.Second)
+	resp = b.ingest(items, "dev1", "", nil, time.Second)
+	assert.Equal(t, 1, resp.Accepted)
+	assert.Equal(t, http.StatusTooManyRequests, resp.Results[1].Status, "Messages over the quota must be rejected")
+	assert.Equal(t, errQuotaExceeded.Error(), resp.Results[1].Error)
+	assert.True(t, resp.Results[1].RetryAfter > 0)
+	if usage := rates.usage(); assert.Len(t, usage, 1) {
+		assert.Equal(t, int64(3), usage[0].Messages, "Ingested messages must count in the usage")
+	}
+	assert.Empty(t, rates.devices, "Requests must release the device limits")
+}
+
+func TestBroker_IngestOverflow(t *testing.T) {
+	for _, test := range []struct {
+		overflow  string
+		overflows float64
+	}{
+		{overflowDropNewest, 2},
+		// the request is cut off on the first overflow
+		{overflowDisconnect, 1},
+	} {
+		b := newBroker()
+		b.pipeline = stoppedPipeline(1, test.overflow)
+		items := []ingestItem{{body: "1"}, {body: "2"}, {body: "3"}}
+		overflows := pipelineOverflows.with(test.overflow).value()
+
+		// the first message is buffered and the others overflow
+		resp := b.ingest(items, "dev1", "", nil, 100*time.Millisecond)
+		assert.Equal(t, overflows+test.overflows, pipelineOverflows.with(test.overflow).value(), test.overflow)
+		assert.Equal(t, 0, resp.Accepted, test.overflow)
+		assert.Equal(t, 3, resp.Failed, test.overflow)
+		for i, status := range []int{http.StatusGatewayTimeout, http.StatusServiceUnavailable, http.StatusServiceUnavailable} {
+			assert.Equal(t, status, resp.Results[i].Status, "%s: message %d", test.overflow, i)
+		}
+		assert.Equal(t, errBufferFull.Error(), resp.Results[2].Error, test.overflow)
+
+		// the buffered message is delivered once the workers run
+		b.pipeline.run()
+		for i := 0; i < 100 && atomic.LoadInt64(&inflight) > 0; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		assert.Equal(t, int64(0), atomic.LoadInt64(&inflight), test.overflow)
+	}
+}
+
+func TestBroker_IngestDelivery(t *testing.T) {
+	defer func(p Publisher) { pub = p }(pub)
+	out := &chanPublisher{out: make(chan *Message, 2)}
+	pub = out
+	b := newBroker()
+
+	done := make(chan *ingestResponse)
+	items := []ingestItem{{body: "one"}, {body: "two"}}
+	go func() { done <- b.ingest(items, "dev1", "", nil, 200*time.Millisecond) }()
+
+	// the workers may hand the messages on in any order
+	var late *Message
+	for i := 0; i < 2; i++ {
+		m := <-out.out
+		assert.Equal(t, "dev1", m.Device)
+		if m.Body == "one" {
+			m.Delivered(nil)
+		} else {
+			late = m
+		}
+	}
+
+	resp := <-done
+	assert.Equal(t, 1, resp.Accepted)
+	assert.Equal(t, http.StatusAccepted, resp.Results[0].Status)
+	assert.Equal(t, http.StatusGatewayTimeout, resp.Results[1].Status,
+		"Messages not delivered in time must be reported")
+	late.Delivered(nil)
+	assert.Equal(t, int64(0), atomic.LoadInt64(&inflight))
+}
@@ -20,7 +20,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"net/url"
 	"regexp"
@@ -72,7 +71,7 @@ func (a *JwtAuth) Validate(req *http.Request) bool {
 
 		var verifyBytes []byte
 
-		verifyBytes, err := getPublicKeyFromDeviceKeysAPI(deviceID.(string), alg)
+		verifyBytes, err := getPublicKeyFromDeviceKeysAPI(spanFromContext(req.Context()), deviceID.(string), alg)
 		if err != nil {
 			return nil, fmt.Errorf("unable to get public key from device keys API: %v", err)
 		}
@@ -97,17 +96,18 @@ func (a *JwtAuth) Validate(req *http.Request) bool {
 	})
 
 	if err != nil {
+		l := logger.With("remote", req.RemoteAddr)
 		if ve, ok := err.(*jwt.ValidationError); ok {
 			if ve.Errors&jwt.ValidationErrorMalformed != 0 {
-				log.Println("Malformed token")
+				l.Warnf("Malformed token")
 			} else if ve.Errors&(jwt.ValidationErrorExpired|jwt.ValidationErrorNotValidYet) != 0 {
 				// Token is either expired or not active yet
-				log.Println("Token is either expired or not active yet")
+				l.Warnf("Token is either expired or not active yet")
 			} else {
-				log.Println("Couldn't handle this token:", err)
+				l.Warnf("Couldn't handle this token: %v", err)
 			}
 		} else {
-			log.Println("Couldn't handle this token:", err)
+			l.Warnf("Couldn't handle this token: %v", err)
 		}
 
 		return false
@@ -116,17 +116,57 @@ func (a *JwtAuth) Validate(req *http.Request) bool {
 	return token.Valid
 }
 
-func getPublicKeyFromDeviceKeysAPI(deviceID string, alg string) ([]byte, error) {
+// DeviceID returns the device id claimed by the JWT of the request, the token
+// is not verified again as the request is expected to be validated already
+func (a *JwtAuth) DeviceID(req *http.Request) string {
+	token, _ := jwt.ParseFromRequest(req, func(token *jwt.Token) (interface{}, error) {
+		return nil, fmt.Errorf("verification skipped")
+	})
+	if token == nil {
+		return ""
+	}
+	deviceID, _ := token.Claims[deviceIDJWTPayloadFieldName].(string)
+	return deviceID
+}
+
+// TenantID returns the tenant claim of the token, the token is not
+// verified again either
+func (a *JwtAuth) TenantID(req *http.Request) string {
+	token, _ := jwt.ParseFromRequest(req, func(token *jwt.Token) (interface{}, error) {
+		return nil, fmt.Errorf("verification skipped")
+	})
+	if token == nil {
+		return ""
+	}
+	tenant, _ := token.Claims[args.Server.TenantClaim].(string)
+	return tenant
+}
+
+// getPublicKeyFromDeviceKeysAPI retrieves the public key of the device,
+// the request is traced as a child of parent
+func getPublicKeyFromDeviceKeysAPI(parent *Span, deviceID string, alg string) (key []byte, err error) {
+	span := startSpan("gateway.device_keys", spanClient, parent)
+	span.SetAttr("device.id", deviceID)
+	defer func() { span.End(err) }()
+
 	requestURL, err := buildDeviceKeyRequestURL(args.Server.DeviceKeysURI, deviceID, alg)
 	if err != nil {
 		return nil, fmt.Errorf("unable to build a device key request URL: %v", err)
 	}
 
-	resp, err := client.Get(requestURL)
+	req, err := http.NewRequest("GET", requestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build a device key request: %v", err)
+	}
+	if span != nil {
+		req.Header.Set(headerTraceparent, span.Traceparent())
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("unable to access API to retrieve a public key: %v", err)
 	}
 	defer resp.Body.Close()
+	span.SetAttr("http.status_code", resp.StatusCode)
 
 	keyBytes, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
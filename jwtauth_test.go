@@ -61,6 +61,7 @@ func TestJWTAuth_Validate(t *testing.T) {
 	}
 	req.Header.Set("Authorization", "Bearer "+validEcJWT)
 	assert.True(t, jwtAuth.Validate(req), "Valid EC JWT must be accepted")
+	assert.Equal(t, goodDeviceID, deviceID(jwtAuth, req), "Device must be identified by its JWT")
 
 	// Test valid RS JWT
 	validRsJWT, err := getJWT(jwt.SigningMethodRS256, goodDeviceID, rsaPrivateKey)
@@ -129,13 +130,13 @@ func TestGetPublicKeyFromDeviceKeysAPI(t *testing.T) {
 	args.Server.DeviceKeysURI = "http://localhost:" +
 		getServerPortFromRawURL(ts.URL) + testDeviceKeysURIPath
 
-	res, err := getPublicKeyFromDeviceKeysAPI(validSampleDeviceID, validSampleAlg)
+	res, err := getPublicKeyFromDeviceKeysAPI(nil, validSampleDeviceID, validSampleAlg)
 	if err != nil {
 		t.Error(err)
 	}
 	assert.Equal(t, publicKeyBytes, res, "The correct public key must be returned if the API knows the device_id and alg")
 
-	res, err = getPublicKeyFromDeviceKeysAPI("wrong_id", "ES256")
+	res, err = getPublicKeyFromDeviceKeysAPI(nil, "wrong_id", "ES256")
 	assert.Nil(t, res, "No public key should be returned for an incorrect device_id")
 	if err == nil {
 		assert.NotNil(t, err, "Getting public key of unknown device must return error")
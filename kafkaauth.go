@@ -0,0 +1,160 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"strings"
+
+	"github.com/Shopify/sarama"
+	"github.com/xdg/scram"
+)
+
+const (
+	saslPlain       = "plain"
+	saslSCRAMSHA256 = "scram-sha-256"
+	saslSCRAMSHA512 = "scram-sha-512"
+	pemPrefix       = "-----BEGIN"
+)
+
+// scramSHA512 is the SCRAM-SHA-512 hash, scram only provides SHA-1 and SHA-256
+var scramSHA512 scram.HashGeneratorFcn = func() hash.Hash { return sha512.New() }
+
+// configKafkaAuth applies the TLS and SASL settings to the producer config
+func configKafkaAuth(config *sarama.Config, args *PubConfig) error {
+	if args.TLS.Enabled {
+		tlsConfig, err := newTLSConfig(&args.TLS)
+		if err != nil {
+			return err
+		}
+		config.Net.TLS.Enable = true
+		config.Net.TLS.Config = tlsConfig
+	}
+
+	if len(args.SASL.Mechanism) == 0 {
+		return nil
+	}
+	config.Net.SASL.Enable = true
+	config.Net.SASL.User = args.SASL.User
+	config.Net.SASL.Password = args.SASL.Password
+
+	switch args.SASL.Mechanism {
+	case saslPlain:
+		config.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+		return nil
+	case saslSCRAMSHA256:
+		config.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
+		config.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &scramClient{hash: scram.SHA256}
+		}
+	case saslSCRAMSHA512:
+		config.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
+		config.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient {
+			return &scramClient{hash: scramSHA512}
+		}
+	default:
+		return fmt.Errorf("unsupported SASL mechanism: %v", args.SASL.Mechanism)
+	}
+
+	// SCRAM goes through the SaslAuthenticate API introduced in Kafka 1.0
+	if !config.Version.IsAtLeast(sarama.V1_0_0_0) {
+		if len(args.Producer.Version) > 0 {
+			return fmt.Errorf("SASL %s requires version >= 1.0.0", args.SASL.Mechanism)
+		}
+		config.Version = sarama.V1_0_0_0
+	}
+	return nil
+}
+
+// newTLSConfig builds the client TLS configuration, the system roots
+// are used unless a CA is configured
+func newTLSConfig(c *TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{ServerName: c.ServerName}
+
+	if len(c.CA) > 0 {
+		ca, err := loadPEM(c.CA)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load CA: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, errors.New("no certificates found in CA")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if len(c.Cert) > 0 {
+		cert, err := loadPEM(c.Cert)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %v", err)
+		}
+		key, err := loadPEM(c.Key)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client key: %v", err)
+		}
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{pair}
+	}
+
+	return tlsConfig, nil
+}
+
+// loadPEM returns the PEM content passed in directly or read from the file at v
+func loadPEM(v string) ([]byte, error) {
+	if strings.HasPrefix(strings.TrimSpace(v), pemPrefix) {
+		return []byte(v), nil
+	}
+	return ioutil.ReadFile(v)
+}
+
+// scramClient runs the client side of the SCRAM exchange (RFC 5802)
+type scramClient struct {
+	hash  scram.HashGeneratorFcn
+	nonce scram.NonceGeneratorFcn
+	conv  *scram.ClientConversation
+}
+
+// Begin prepares the client for the SCRAM exchange
+func (c *scramClient) Begin(user, password, authzID string) error {
+	client, err := c.hash.NewClient(user, password, authzID)
+	if err != nil {
+		return err
+	}
+	if c.nonce != nil {
+		client = client.WithNonceGenerator(c.nonce)
+	}
+	c.conv = client.NewConversation()
+	return nil
+}
+
+// Step computes the response to the server challenge
+func (c *scramClient) Step(challenge string) (string, error) {
+	return c.conv.Step(challenge)
+}
+
+// Done reports whether the SCRAM exchange is over
+func (c *scramClient) Done() bool {
+	return c.conv.Done()
+}
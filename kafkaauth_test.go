@@ -0,0 +1,83 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/xdg/scram"
+)
+
+// exchange from RFC 7677, section 3
+func TestScramClient_SHA256(t *testing.T) {
+	c := &scramClient{hash: scram.SHA256, nonce: func() string { return "rOprNGfwEbeRWgbNEkqO" }}
+	assert.Nil(t, c.Begin("user", "pencil", ""))
+
+	first, err := c.Step("")
+	assert.Nil(t, err)
+	assert.Equal(t, "n,,n=user,r=rOprNGfwEbeRWgbNEkqO", first)
+
+	final, err := c.Step("r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0," +
+		"s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")
+	assert.Nil(t, err)
+	assert.Equal(t, "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,"+
+		"p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=", final)
+	assert.False(t, c.Done())
+
+	_, err = c.Step("v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=")
+	assert.Nil(t, err, "Valid server signature must be accepted")
+	assert.True(t, c.Done())
+}
+
+func TestScramClient_BadServer(t *testing.T) {
+	c := &scramClient{hash: scram.SHA256, nonce: func() string { return "abc" }}
+	assert.Nil(t, c.Begin("user", "pencil", ""))
+	c.Step("")
+	_, err := c.Step("r=xyz,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")
+	assert.NotNil(t, err, "Server nonce must extend the client one")
+
+	c = &scramClient{hash: scram.SHA256, nonce: func() string { return "abc" }}
+	assert.Nil(t, c.Begin("user", "pencil", ""))
+	c.Step("")
+	c.Step("r=abcdef,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")
+	_, err = c.Step("v=bm90IGl0")
+	assert.NotNil(t, err, "Invalid server signature must be rejected")
+}
+
+func TestConfigKafkaAuth(t *testing.T) {
+	config := sarama.NewConfig()
+	err := configKafkaAuth(config, &PubConfig{
+		SASL: SASLConfig{Mechanism: saslSCRAMSHA512, User: "user", Password: "pencil"},
+	})
+	assert.Nil(t, err)
+	assert.True(t, config.Net.SASL.Enable)
+	assert.Equal(t, sarama.SASLMechanism(sarama.SASLTypeSCRAMSHA512), config.Net.SASL.Mechanism)
+	assert.True(t, config.Version.IsAtLeast(sarama.V1_0_0_0), "SCRAM requires Kafka 1.0")
+	assert.Nil(t, config.Validate())
+	client := config.Net.SASL.SCRAMClientGeneratorFunc()
+	assert.Nil(t, client.Begin("user", "pencil", ""))
+	first, err := client.Step("")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(first, "n,,n=user,r="))
+
+	err = configKafkaAuth(sarama.NewConfig(), &PubConfig{
+		TLS: TLSConfig{Enabled: true, CA: "-----BEGIN CERTIFICATE-----\nbogus\n-----END CERTIFICATE-----\n"},
+	})
+	assert.NotNil(t, err, "CA without certificates must be rejected")
+}
@@ -0,0 +1,503 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// Kafka record headers carrying the message envelope
+const (
+	headerID          = "id"
+	headerReceived    = "received"
+	headerGateway     = "gateway"
+	headerDevice      = "device"
+	headerContentType = "content-type"
+	headerAuth        = "auth"
+	headerTraceparent = "traceparent"
+)
+
+// defaultRoute attaches all of the headers and sets the record timestamp
+var defaultRoute = kafkaRoute{
+	headers: []string{headerID, headerReceived, headerGateway,
+		headerDevice, headerContentType, headerAuth, headerTraceparent},
+	timestamp: true,
+}
+
+const (
+	// kafkaHealthWindow is how long a delivery keeps the producer ready
+	// without looking at the brokers
+	kafkaHealthWindow = 30 * time.Second
+	// kafkaHealthTimeout bounds the readiness check of the brokers
+	kafkaHealthTimeout = time.Second
+)
+
+// kafkaConnectDelay is how long the publisher waits before connecting
+// again to brokers it could not reach
+var kafkaConnectDelay = 5 * time.Second
+
+// kafkaRoute is the record layout of a topic
+type kafkaRoute struct {
+	headers   []string
+	timestamp bool
+}
+
+// KafkaPublisher is the type representing Kafka publisher imp
+type KafkaPublisher struct {
+	topic    string
+	gateway  string
+	route    kafkaRoute
+	attempts int
+	uri      []string
+	config   *sarama.Config
+	client   sarama.Client
+	producer sarama.AsyncProducer
+	pending  int64
+
+	// connected is closed once the producer is connected or closed
+	connected chan bool
+
+	// unix nanoseconds of the last delivery
+	delivered  int64
+	refreshing int32
+
+	mu     sync.RWMutex
+	closed bool
+	done   chan bool
+}
+
+// NewKafkaPublisher creates a new Kafka publisher object
+func NewKafkaPublisher() Publisher {
+	return &KafkaPublisher{}
+}
+
+// Config connects the producer to the configured Kafka brokers, when none
+// of them is reachable the publisher keeps on connecting in the background
+func (p *KafkaPublisher) Config(clientID string, args *PubConfig) {
+
+	config, err := newKafkaConfig(clientID, args)
+	if err != nil {
+		logger.Fatalf("Invalid Kafka producer configuration: %v", err)
+	}
+
+	route, err := newKafkaRoute(args.Topic, args.Routes)
+	if err != nil {
+		logger.Fatalf("Invalid Kafka route configuration: %v", err)
+	}
+	if len(route.headers) > 0 && !config.Version.IsAtLeast(sarama.V0_11_0_0) {
+		logger.Warnf("Kafka record headers require version 0.11.0 or newer, not sending them to [%s]", args.Topic)
+	}
+	if route.timestamp && !config.Version.IsAtLeast(sarama.V0_10_0_0) {
+		logger.Warnf("Kafka record timestamps require version 0.10.0 or newer, not setting them on [%s]", args.Topic)
+	}
+
+	p.topic = args.Topic
+	p.gateway = clientID
+	p.route = route
+	p.attempts = config.Producer.Retry.Max + 1
+	p.uri = args.URI
+	p.config = config
+	p.connected = make(chan bool)
+	p.done = make(chan bool)
+	if err := p.connect(); err != nil {
+		logger.Errorf("Failed to connect to Kafka, retrying: %v", err)
+		go p.reconnect(kafkaConnectDelay)
+	}
+
+	labels := []string{"topic"}
+	newCollector("gateway_kafka_producer_pending", "Number of messages buffered by the Kafka producer.",
+		metricGauge, labels, func() []metricSample {
+			return []metricSample{{labels: []string{p.topic}, value: float64(atomic.LoadInt64(&p.pending))}}
+		})
+	newCollector("gateway_kafka_producer_buffer_size", "Number of messages the Kafka producer buffers per partition.",
+		metricGauge, labels, func() []metricSample {
+			return []metricSample{{labels: []string{p.topic}, value: float64(config.ChannelBufferSize)}}
+		})
+
+}
+
+// connect starts the producer unless the publisher was closed meanwhile
+func (p *KafkaPublisher) connect() error {
+	client, err := sarama.NewClient(p.uri, p.config)
+	if err != nil {
+		return err
+	}
+	producer, err := sarama.NewAsyncProducerFromClient(client)
+	if err != nil {
+		client.Close()
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		producer.Close()
+		client.Close()
+		return nil
+	}
+	p.client = client
+	p.producer = producer
+	close(p.connected)
+	go p.dispatch()
+	return nil
+}
+
+// reconnect connects to the brokers once they are back, trying again
+// after the delay
+func (p *KafkaPublisher) reconnect(delay time.Duration) {
+	for {
+		time.Sleep(delay)
+		p.mu.RLock()
+		closed := p.closed
+		p.mu.RUnlock()
+		if closed {
+			return
+		}
+		if err := p.connect(); err != nil {
+			logger.Debugf("Failed to connect to Kafka, retrying: %v", err)
+			continue
+		}
+		logger.Infof("Connected to Kafka")
+		return
+	}
+}
+
+// Start fires a publisher listener, the messages are only taken once the
+// producer is connected so that a spool in front of it takes them meanwhile
+func (p *KafkaPublisher) Start(in <-chan *Message) {
+
+	<-p.connected
+	for msg := range in {
+		p.mu.RLock()
+		if p.closed {
+			p.mu.RUnlock()
+			msg.Delivered(sarama.ErrShuttingDown)
+			continue
+		}
+		atomic.AddInt64(&p.pending, 1)
+		p.producer.Input() <- p.produce(msg)
+		p.mu.RUnlock()
+		logger.Message(msg).Debugf("Queue[%s] < %s", p.topic, logBody(msg.Body))
+	}
+
+}
+
+// Close flushes the buffered messages and waits for their delivery
+// to be reported before the producer stops
+func (p *KafkaPublisher) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	if p.producer == nil {
+		// never connected, the waiting messages are rejected
+		close(p.connected)
+		p.mu.Unlock()
+		return nil
+	}
+	p.producer.AsyncClose()
+	p.mu.Unlock()
+	<-p.done
+	if p.client != nil {
+		return p.client.Close()
+	}
+	return nil
+}
+
+// health reports whether the producer delivered a message lately or is
+// connected to the cluster. It never waits for Kafka for long, when none of
+// the brokers is connected the metadata is refreshed in the background
+func (p *KafkaPublisher) health(status map[string]error) {
+	p.mu.RLock()
+	client, closed := p.client, p.closed
+	p.mu.RUnlock()
+	if client == nil && !closed {
+		status["kafka"] = sarama.ErrOutOfBrokers
+		return
+	}
+	if client == nil || client.Closed() {
+		status["kafka"] = sarama.ErrClosedClient
+		return
+	}
+	if time.Since(time.Unix(0, atomic.LoadInt64(&p.delivered))) < kafkaHealthWindow {
+		status["kafka"] = nil
+		return
+	}
+
+	// a broker being dialed holds its lock until the dial times out
+	connected := make(chan bool, 1)
+	go func() {
+		for _, b := range client.Brokers() {
+			if ok, _ := b.Connected(); ok {
+				connected <- true
+				return
+			}
+		}
+		connected <- false
+	}()
+	select {
+	case ok := <-connected:
+		if ok {
+			status["kafka"] = nil
+			return
+		}
+	case <-time.After(kafkaHealthTimeout):
+	}
+
+	if atomic.CompareAndSwapInt32(&p.refreshing, 0, 1) {
+		go func() {
+			defer atomic.StoreInt32(&p.refreshing, 0)
+			if err := client.RefreshMetadata(p.topic); err != nil {
+				logger.Debugf("Kafka metadata refresh failed: %v", err)
+			}
+		}()
+	}
+	status["kafka"] = sarama.ErrOutOfBrokers
+}
+
+// produce creates the record of the message, its envelope is attached
+// as headers so consumers can route it without parsing the value
+func (p *KafkaPublisher) produce(msg *Message) *sarama.ProducerMessage {
+	m := &sarama.ProducerMessage{
+		Topic:    p.topic,
+		Key:      nil,
+		Value:    sarama.StringEncoder(msg.ToBytes()),
+		Metadata: msg,
+	}
+	if p.route.timestamp {
+		m.Timestamp = msg.On
+	}
+	for _, name := range p.route.headers {
+		var value string
+		switch name {
+		case headerID:
+			value = msg.ID
+		case headerReceived:
+			value = msg.On.Format(time.RFC3339Nano)
+		case headerGateway:
+			value = p.gateway
+		case headerDevice:
+			value = msg.Device
+		case headerContentType:
+			value = msg.ContentType()
+		case headerAuth:
+			value = msg.Auth
+		case headerTraceparent:
+			value = msg.Trace
+		}
+		if len(value) > 0 {
+			m.Headers = append(m.Headers, sarama.RecordHeader{
+				Key:   []byte(name),
+				Value: []byte(value),
+			})
+		}
+	}
+	return m
+}
+
+// newKafkaRoute resolves the record layout of the topic, the routes are
+// keyed by topic with "*" matching any topic not listed
+func newKafkaRoute(topic string, routes map[string]RouteConfig) (kafkaRoute, error) {
+	conf, ok := routes[topic]
+	if !ok {
+		conf, ok = routes["*"]
+	}
+	if !ok {
+		return defaultRoute, nil
+	}
+
+	route := defaultRoute
+	if conf.Timestamp != nil {
+		route.timestamp = *conf.Timestamp
+	}
+	if conf.Headers != nil {
+		route.headers = nil
+		for _, name := range conf.Headers {
+			switch name {
+			case headerID, headerReceived, headerGateway,
+				headerDevice, headerContentType, headerAuth, headerTraceparent:
+				route.headers = append(route.headers, name)
+			default:
+				return route, fmt.Errorf("invalid header for [%s]: %v", topic, name)
+			}
+		}
+	}
+	return route, nil
+}
+
+// dispatch reports the delivery of each message back to its sender,
+// the message travels along with its producer message as metadata
+func (p *KafkaPublisher) dispatch() {
+	defer close(p.done)
+	successes, errs := p.producer.Successes(), p.producer.Errors()
+	for successes != nil || errs != nil {
+		select {
+		case m, ok := <-successes:
+			if !ok {
+				successes = nil
+				continue
+			}
+			atomic.AddInt64(&p.pending, -1)
+			atomic.StoreInt64(&p.delivered, time.Now().UnixNano())
+			msg := m.Metadata.(*Message)
+			recordDelivery("kafka", p.topic, msg, nil)
+			msg.Delivered(nil)
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			atomic.AddInt64(&p.pending, -1)
+			msg := err.Msg.Metadata.(*Message)
+			logger.Message(msg).Errorf("Error on queue send for [%s]: %v", p.topic, err.Err)
+			recordDelivery("kafka", p.topic, msg, err.Err)
+			msg.Failed(err.Err, p.attempts)
+		}
+	}
+}
+
+// newKafkaConfig maps the publisher configuration onto the producer one
+// and validates the result
+func newKafkaConfig(clientID string, args *PubConfig) (*sarama.Config, error) {
+
+	config := sarama.NewConfig()
+	prod := &args.Producer
+
+	config.ClientID = clientID
+
+	// Version, record headers need at least 0.11
+	config.Version = sarama.V0_11_0_0
+	if len(prod.Version) > 0 {
+		v, err := sarama.ParseKafkaVersion(prod.Version)
+		if err != nil {
+			return nil, err
+		}
+		config.Version = v
+	}
+
+	// Acks, falling back to the acks flag
+	acks := prod.Acks
+	if len(acks) == 0 {
+		switch {
+		case args.Ack, prod.Idempotent:
+			acks = "all"
+		default:
+			acks = "local"
+		}
+	}
+	switch acks {
+	case "none":
+		config.Producer.RequiredAcks = sarama.NoResponse
+	case "local":
+		config.Producer.RequiredAcks = sarama.WaitForLocal
+	case "all":
+		config.Producer.RequiredAcks = sarama.WaitForAll
+	default:
+		return nil, fmt.Errorf("invalid acks: %v", acks)
+	}
+
+	// Compression, falling back to the compress flag
+	codec := prod.Compression
+	if len(codec) == 0 {
+		if args.Compress {
+			codec = "snappy"
+		} else {
+			codec = "none"
+		}
+	}
+	switch codec {
+	case "none":
+		config.Producer.Compression = sarama.CompressionNone
+	case "gzip":
+		config.Producer.Compression = sarama.CompressionGZIP
+	case "snappy":
+		config.Producer.Compression = sarama.CompressionSnappy
+	case "lz4":
+		config.Producer.Compression = sarama.CompressionLZ4
+	case "zstd":
+		if !config.Version.IsAtLeast(sarama.V2_1_0_0) {
+			return nil, fmt.Errorf("zstd compression requires version >= 2.1.0")
+		}
+		config.Producer.Compression = sarama.CompressionZSTD
+	default:
+		return nil, fmt.Errorf("invalid compression: %v", codec)
+	}
+
+	// Delivery reports are read by dispatch
+	config.Producer.Return.Successes = true
+	config.Producer.Return.Errors = true
+
+	// Flush Intervals, falling back to the flush frequency in seconds
+	switch {
+	case prod.FlushMs > 0:
+		config.Producer.Flush.Frequency = time.Duration(prod.FlushMs) * time.Millisecond
+	case args.FlushFreq > 0:
+		config.Producer.Flush.Frequency = time.Duration(args.FlushFreq) * time.Second
+	default:
+		config.Producer.Flush.Frequency = 1 * time.Second
+	}
+	config.Producer.Flush.Bytes = prod.FlushBytes
+	config.Producer.Flush.Messages = prod.FlushMessages
+
+	if prod.MaxMessageBytes != 0 {
+		config.Producer.MaxMessageBytes = prod.MaxMessageBytes
+	}
+
+	// Retries
+	if prod.Retries != nil {
+		config.Producer.Retry.Max = *prod.Retries
+	}
+	if prod.RetryBackoffMs > 0 {
+		config.Producer.Retry.Backoff = time.Duration(prod.RetryBackoffMs) * time.Millisecond
+	}
+
+	// Idempotence needs a single in-flight request per broker to keep the order
+	if prod.Idempotent {
+		config.Producer.Idempotent = true
+		config.Net.MaxOpenRequests = 1
+	}
+
+	// Partitioner
+	switch prod.Partitioner {
+	case "", "hash":
+		config.Producer.Partitioner = sarama.NewHashPartitioner
+	case "random":
+		config.Producer.Partitioner = sarama.NewRandomPartitioner
+	case "roundrobin":
+		config.Producer.Partitioner = sarama.NewRoundRobinPartitioner
+	case "reference":
+		config.Producer.Partitioner = sarama.NewReferenceHashPartitioner
+	default:
+		return nil, fmt.Errorf("invalid partitioner: %v", prod.Partitioner)
+	}
+
+	// TLS and SASL
+	if err := configKafkaAuth(config, args); err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
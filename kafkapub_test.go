@@ -0,0 +1,220 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewKafkaConfig_Legacy(t *testing.T) {
+	config, err := newKafkaConfig("test", &PubConfig{Ack: true, Compress: true, FlushFreq: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, sarama.V0_11_0_0, config.Version, "Version must default to one supporting headers")
+	assert.Equal(t, sarama.WaitForAll, config.Producer.RequiredAcks)
+	assert.Equal(t, sarama.CompressionSnappy, config.Producer.Compression)
+	assert.Equal(t, 2*time.Second, config.Producer.Flush.Frequency)
+}
+
+func TestNewKafkaConfig_Producer(t *testing.T) {
+	retries := 0
+	config, err := newKafkaConfig("test", &PubConfig{
+		Producer: ProducerConfig{
+			Acks:            "none",
+			Compression:     "zstd",
+			FlushBytes:      1024,
+			FlushMessages:   10,
+			FlushMs:         50,
+			MaxMessageBytes: 2048,
+			Retries:         &retries,
+			RetryBackoffMs:  20,
+			Partitioner:     "roundrobin",
+			Version:         "2.1.0",
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, sarama.NoResponse, config.Producer.RequiredAcks)
+	assert.Equal(t, sarama.CompressionZSTD, config.Producer.Compression)
+	assert.Equal(t, 1024, config.Producer.Flush.Bytes)
+	assert.Equal(t, 10, config.Producer.Flush.Messages)
+	assert.Equal(t, 50*time.Millisecond, config.Producer.Flush.Frequency)
+	assert.Equal(t, 2048, config.Producer.MaxMessageBytes)
+	assert.Equal(t, 0, config.Producer.Retry.Max, "Retries must be configurable down to 0")
+	assert.Equal(t, 20*time.Millisecond, config.Producer.Retry.Backoff)
+	assert.Equal(t, sarama.V2_1_0_0, config.Version)
+}
+
+func TestNewKafkaConfig_Idempotent(t *testing.T) {
+	config, err := newKafkaConfig("test", &PubConfig{
+		Producer: ProducerConfig{Idempotent: true, Version: "0.11.0.0"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, sarama.WaitForAll, config.Producer.RequiredAcks, "Idempotence defaults acks to all")
+	assert.Equal(t, 1, config.Net.MaxOpenRequests)
+
+	_, err = newKafkaConfig("test", &PubConfig{
+		Producer: ProducerConfig{Idempotent: true, Version: "0.10.2.0"},
+	})
+	assert.NotNil(t, err, "Idempotence requires Kafka 0.11")
+
+	_, err = newKafkaConfig("test", &PubConfig{
+		Producer: ProducerConfig{Idempotent: true, Acks: "local", Version: "1.0.0"},
+	})
+	assert.NotNil(t, err, "Idempotence requires acks from all replicas")
+}
+
+func TestNewKafkaConfig_Invalid(t *testing.T) {
+	for _, prod := range []ProducerConfig{
+		{Acks: "some"},
+		{Compression: "brotli"},
+		{Compression: "zstd"},
+		{Partitioner: "sticky"},
+		{Version: "latest"},
+		{MaxMessageBytes: -1},
+	} {
+		_, err := newKafkaConfig("test", &PubConfig{Producer: prod})
+		assert.NotNil(t, err, "Invalid producer config must be rejected: %+v", prod)
+	}
+}
+
+func TestKafkaPublisher_Delivery(t *testing.T) {
+	config := sarama.NewConfig()
+	config.Producer.Return.Successes = true
+	producer := mocks.NewAsyncProducer(t, config)
+
+	p := &KafkaPublisher{topic: "messages", producer: producer, connected: make(chan bool), done: make(chan bool)}
+	close(p.connected)
+	go p.dispatch()
+
+	producer.ExpectInputAndSucceed()
+	producer.ExpectInputAndFail(errors.New("boom"))
+
+	results := []chan error{make(chan error, 1), make(chan error, 1)}
+	in := make(chan *Message, 2)
+	for _, result := range results {
+		result := result
+		msg := NewMessage("test")
+		msg.delivered = func(err error) { result <- err }
+		in <- msg
+	}
+	close(in)
+	p.Start(in)
+
+	assert.Nil(t, <-results[0], "Delivered message must be acknowledged")
+	assert.Equal(t, errors.New("boom"), <-results[1], "Failed message must report its error")
+
+	assert.Nil(t, p.Close())
+	late := NewMessage("late")
+	result := make(chan error, 1)
+	late.delivered = func(err error) { result <- err }
+	in = make(chan *Message, 1)
+	in <- late
+	close(in)
+	p.Start(in)
+	assert.Equal(t, sarama.ErrShuttingDown, <-result, "Messages sent after close must be rejected")
+}
+
+func TestNewKafkaRoute(t *testing.T) {
+	route, err := newKafkaRoute("messages", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, defaultRoute, route, "Topics without a route must get all headers")
+
+	off := false
+	routes := map[string]RouteConfig{
+		"messages": {Headers: []string{headerID, headerDevice}},
+		"*":        {Headers: []string{}, Timestamp: &off},
+	}
+	route, err = newKafkaRoute("messages", routes)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{headerID, headerDevice}, route.headers)
+	assert.True(t, route.timestamp)
+
+	route, err = newKafkaRoute("other", routes)
+	assert.Nil(t, err)
+	assert.Empty(t, route.headers, "Other topics must fall back to the wildcard route")
+	assert.False(t, route.timestamp)
+
+	_, err = newKafkaRoute("messages", map[string]RouteConfig{"messages": {Headers: []string{"body"}}})
+	assert.NotNil(t, err, "Unknown headers must be rejected")
+}
+
+func TestKafkaPublisher_Produce(t *testing.T) {
+	p := &KafkaPublisher{topic: "messages", gateway: "g1", route: defaultRoute}
+	msg := NewMessage(`{"temp":21}`)
+	msg.Device = "dev1"
+	msg.Auth = "jwt"
+	msg.Trace = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	m := p.produce(msg)
+	assert.Equal(t, msg.On, m.Timestamp, "Record timestamp must be the receive time")
+
+	headers := make(map[string]string)
+	for _, h := range m.Headers {
+		headers[string(h.Key)] = string(h.Value)
+	}
+	assert.Equal(t, map[string]string{
+		headerID:          msg.ID,
+		headerReceived:    msg.On.Format(time.RFC3339Nano),
+		headerGateway:     "g1",
+		headerDevice:      "dev1",
+		headerContentType: "application/json",
+		headerAuth:        "jwt",
+		headerTraceparent: msg.Trace,
+	}, headers)
+
+	p.route = kafkaRoute{headers: []string{headerDevice}}
+	m = p.produce(NewMessage("plain"))
+	assert.True(t, m.Timestamp.IsZero())
+	assert.Empty(t, m.Headers, "Unknown devices must not be sent as empty headers")
+}
+
+func TestKafkaPublisher_Unreachable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gateway-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(d time.Duration) { kafkaConnectDelay = d }(kafkaConnectDelay)
+	kafkaConnectDelay = 10 * time.Millisecond
+
+	// the gateway starts while none of the brokers is reachable
+	inner := NewKafkaPublisher().(*KafkaPublisher)
+	p := NewSpoolPublisher(inner).(*SpoolPublisher)
+	p.Config("g1", &PubConfig{URI: []string{"127.0.0.1:1"}, Topic: "messages",
+		Spool: SpoolConfig{Dir: dir, Timeout: 10}})
+	status := make(map[string]error)
+	checkHealth(inner, status)
+	assert.Equal(t, sarama.ErrOutOfBrokers, status["kafka"], "Unconnected producer must not be ready")
+
+	// the spool takes the messages meanwhile
+	msg := NewMessage("one")
+	result := make(chan error, 1)
+	msg.delivered = func(err error) { result <- err }
+	sendAll(p, []*Message{msg})
+	assert.Nil(t, <-result, "Messages must be spooled while Kafka is down")
+	assert.Equal(t, int64(1), p.Depth())
+
+	assert.Nil(t, p.Close())
+	checkHealth(inner, status)
+	assert.Equal(t, sarama.ErrClosedClient, status["kafka"])
+}
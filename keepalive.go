@@ -0,0 +1,115 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"code.google.com/p/go.net/websocket"
+)
+
+const (
+	defaultPingInterval = 30
+	defaultReadTimeout  = 75
+)
+
+var (
+	errReadTimeout = errors.New("no frame received in time")
+	errIdleTimeout = errors.New("no message received in time")
+
+	// pingCodec sends an empty ping frame which the pong echoes back
+	pingCodec = websocket.Codec{Marshal: func(v interface{}) ([]byte, byte, error) {
+		return nil, websocket.PingFrame, nil
+	}}
+)
+
+// ping asks the client for a pong to tell whether it is still there
+func (c *handler) ping() {
+	if err := pingCodec.Send(c.ws, nil); err != nil {
+		c.log.Debugf("ping failed: %v", err)
+	}
+}
+
+// readMessage reads the next message of the client, pongs are read here as
+// the websocket package fails on them leaving their payload unread
+func (c *handler) readMessage(m *string) error {
+	for {
+		c.ws.SetReadDeadline(c.readDeadline())
+		frame, err := c.ws.NewFrameReader()
+		if err != nil {
+			return err
+		}
+		if frame.PayloadType() == websocket.PongFrame {
+			if _, err := io.Copy(ioutil.Discard, frame); err != nil {
+				return err
+			}
+			c.seen(false)
+			continue
+		}
+		if frame, err = c.ws.HandleFrame(frame); err != nil {
+			return err
+		} else if frame == nil {
+			// pings are answered by the websocket package
+			continue
+		}
+		data, err := ioutil.ReadAll(frame)
+		if err != nil {
+			return err
+		}
+		return msg.Unmarshal(data, frame.PayloadType(), m)
+	}
+}
+
+// seen records a frame from the client, message tells whether
+// it was a message rather than a pong
+func (c *handler) seen(message bool) {
+	now := time.Now().UnixNano()
+	atomic.StoreInt64(&c.lastSeen, now)
+	if message {
+		atomic.StoreInt64(&c.lastMessage, now)
+	}
+}
+
+// readDeadline returns when the next frame is due, the client is either
+// gone after the read timeout or idle after the idle timeout
+func (c *handler) readDeadline() time.Time {
+	deadline := time.Unix(0, atomic.LoadInt64(&c.lastSeen)).Add(c.readTimeout)
+	if c.idleTimeout > 0 {
+		if idle := time.Unix(0, atomic.LoadInt64(&c.lastMessage)).Add(c.idleTimeout); idle.Before(deadline) {
+			deadline = idle
+		}
+	}
+	return deadline
+}
+
+// timeout returns why the read deadline passed
+func (c *handler) timeout() error {
+	if c.idleTimeout > 0 && time.Since(time.Unix(0, atomic.LoadInt64(&c.lastMessage))) >= c.idleTimeout {
+		return errIdleTimeout
+	}
+	return errReadTimeout
+}
+
+// isTimeout tells whether the read failed on its deadline
+func isTimeout(err error) bool {
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
@@ -0,0 +1,151 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"code.google.com/p/go.net/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// serveKeepalive serves a broker whose clients use the given keepalive
+func serveKeepalive(t *testing.T, b *broker, ping, read, idle time.Duration) *httptest.Server {
+	ts, _ := serveTestBroker(t, b)
+	ts.Config.Handler = websocket.Handler(func(ws *websocket.Conn) {
+		h := newClient(ws, b)
+		h.pingEvery, h.readTimeout, h.idleTimeout = ping, read, idle
+		b.add(h)
+		presence.connected(h)
+		h.listen()
+	})
+	return ts
+}
+
+func TestKeepalive_ReadTimeout(t *testing.T) {
+	pub = &ackPublisher{}
+	p, events := newTestPresence(t, 2)
+	presence = p
+	defer func() { presence = nil }()
+	b := newBroker()
+	ts := serveKeepalive(t, b, 50*time.Millisecond, 200*time.Millisecond, 0)
+	defer ts.Close()
+
+	// a client that never reads does not answer the pings
+	ws := dialTestServer(t, ts, "/")
+	defer ws.Close()
+	nextEvent(t, events)
+	e := nextEvent(t, events)
+	assert.Equal(t, presenceDisconnected, e.Type)
+	assert.Equal(t, reasonTimeout, e.Reason, "Silent clients must be cut off after the read timeout")
+	waitClients(t, b, 0)
+	assert.Nil(t, p.Close())
+}
+
+func TestKeepalive_Pongs(t *testing.T) {
+	pub = &ackPublisher{}
+	p, events := newTestPresence(t, 2)
+	presence = p
+	defer func() { presence = nil }()
+	b := newBroker()
+	ts := serveKeepalive(t, b, 50*time.Millisecond, 200*time.Millisecond, 0)
+	defer ts.Close()
+
+	// the websocket package fails on empty pings, the client sends
+	// unsolicited pongs instead
+	pong := websocket.Codec{Marshal: func(v interface{}) ([]byte, byte, error) {
+		return nil, websocket.PongFrame, nil
+	}}
+	ws := dialTestServer(t, ts, "/")
+	defer ws.Close()
+	c := waitClients(t, b, 1)[0]
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, pong.Send(ws, nil))
+		time.Sleep(50 * time.Millisecond)
+	}
+	waitClients(t, b, 1)
+	info := c.info()
+	assert.True(t, info.LastSeen.After(info.LastActivity), "Pongs must count as seen but not as activity")
+	ws.Close()
+	nextEvent(t, events)
+	assert.Equal(t, reasonClientClosed, nextEvent(t, events).Reason)
+	assert.Nil(t, p.Close())
+}
+
+func TestKeepalive_PongPayload(t *testing.T) {
+	defer func(p Publisher) { pub = p }(pub)
+	out := make(chan *Message, 1)
+	pub = &chanPublisher{out: out}
+	ts, b, done := serveDone(t)
+	defer ts.Close()
+
+	// the payload of the pong must not be taken for the next frame
+	pong := websocket.Codec{Marshal: func(v interface{}) ([]byte, byte, error) {
+		return []byte(v.(string)), websocket.PongFrame, nil
+	}}
+	ws := dialTestServer(t, ts, "/")
+	defer ws.Close()
+	assert.Nil(t, pong.Send(ws, "keepalive"))
+	assert.Nil(t, websocket.Message.Send(ws, "after pong"))
+	select {
+	case m := <-out:
+		assert.Equal(t, "after pong", m.Body)
+		m.Delivered(nil)
+	case <-time.After(5 * time.Second):
+		t.Fatal("message after the pong was not received")
+	}
+	assert.Len(t, b.list(), 1, "Pongs with a payload must not end the connection")
+	ws.Close()
+	waitDone(t, done)
+}
+
+func TestKeepalive_IdleTimeout(t *testing.T) {
+	pub = &ackPublisher{}
+	p, events := newTestPresence(t, 2)
+	presence = p
+	defer func() { presence = nil }()
+	b := newBroker()
+	ts := serveKeepalive(t, b, time.Minute, 2*time.Minute, 300*time.Millisecond)
+	defer ts.Close()
+
+	ws := dialTestServer(t, ts, "/")
+	defer ws.Close()
+	nextEvent(t, events)
+	assert.Nil(t, websocket.Message.Send(ws, "hello"))
+
+	start := time.Now()
+	var m string
+	ws.SetDeadline(time.Now().Add(5 * time.Second))
+	assert.NotNil(t, websocket.Message.Receive(ws, &m), "Idle clients must be disconnected")
+	assert.True(t, time.Since(start) >= 200*time.Millisecond, "Messages must reset the idle timeout")
+	e := nextEvent(t, events)
+	assert.Equal(t, reasonIdle, e.Reason)
+	assert.Nil(t, p.Close())
+}
+
+func TestHandler_ReadDeadline(t *testing.T) {
+	now := time.Now()
+	c := &handler{lastSeen: now.UnixNano(), lastMessage: now.Add(-time.Minute).UnixNano(), readTimeout: time.Minute}
+	assert.Equal(t, now.Add(time.Minute).UnixNano(), c.readDeadline().UnixNano())
+	assert.Equal(t, errReadTimeout, c.timeout())
+
+	c.idleTimeout = 30 * time.Second
+	assert.Equal(t, now.Add(-30*time.Second).UnixNano(), c.readDeadline().UnixNano(),
+		"The idle timeout must win when it is due first")
+	assert.Equal(t, errIdleTimeout, c.timeout())
+}
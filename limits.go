@@ -0,0 +1,248 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	closeMessageTooBig = 1009
+
+	defaultMaxMessageKB = 1024
+
+	// limits a connection is rejected for, the device limit is checked
+	// once the device is authenticated
+	limitTotal  = "total"
+	limitDevice = "device"
+	limitIP     = "ip"
+)
+
+var (
+	errMessageTooBig      = errors.New("message too big")
+	errTooManyConnections = errors.New("too many connections")
+
+	connectionsRejected = newCounter("gateway_connections_rejected_total",
+		"Connections rejected for hitting a limit.", "limit")
+)
+
+// connLimits counts the connections by device and remote address,
+// a connection is counted from its upgrade until it ends and by device
+// from its authentication
+type connLimits struct {
+	sync.Mutex
+	max, maxDevice, maxIP int
+	total                 int
+	devices               map[string]int
+	ips                   map[string]int
+}
+
+func newConnLimits(max, maxDevice, maxIP int) *connLimits {
+	return &connLimits{
+		max:       max,
+		maxDevice: maxDevice,
+		maxIP:     maxIP,
+		devices:   make(map[string]int),
+		ips:       make(map[string]int),
+	}
+}
+
+// acquire counts a new connection unless it hits the total or the
+// address limit, in which case it returns the limit, 0 means unlimited
+func (l *connLimits) acquire(ip string) string {
+	l.Lock()
+	defer l.Unlock()
+	switch {
+	case l.max > 0 && l.total >= l.max:
+		return limitTotal
+	case l.maxIP > 0 && l.ips[ip] >= l.maxIP:
+		return limitIP
+	}
+	l.total++
+	l.ips[ip]++
+	return ""
+}
+
+// release stops counting a connection
+func (l *connLimits) release(ip string) {
+	l.Lock()
+	defer l.Unlock()
+	l.total--
+	if l.ips[ip]--; l.ips[ip] <= 0 {
+		delete(l.ips, ip)
+	}
+}
+
+// acquireDevice counts the connection of an authenticated device unless
+// it hits the device limit, connections without a device are not limited
+func (l *connLimits) acquireDevice(device string) bool {
+	if len(device) == 0 {
+		return true
+	}
+	l.Lock()
+	defer l.Unlock()
+	if l.maxDevice > 0 && l.devices[device] >= l.maxDevice {
+		return false
+	}
+	l.devices[device]++
+	return true
+}
+
+// releaseDevice stops counting the connection of a device
+func (l *connLimits) releaseDevice(device string) {
+	if len(device) == 0 {
+		return
+	}
+	l.Lock()
+	defer l.Unlock()
+	if l.devices[device]--; l.devices[device] <= 0 {
+		delete(l.devices, device)
+	}
+}
+
+// remoteIP returns the address of the request without its port, behind
+// proxies appending to X-Forwarded-For it is the address the first of
+// them received the request from
+func remoteIP(req *http.Request, proxies int) string {
+	if proxies > 0 {
+		if hops := forwardedFor(req); len(hops) > 0 {
+			i := len(hops) - proxies
+			if i < 0 {
+				i = 0
+			}
+			return hops[i]
+		}
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// forwardedFor lists the addresses of the X-Forwarded-For headers,
+// the address added by the closest proxy comes last
+func forwardedFor(req *http.Request) []string {
+	var hops []string
+	for _, h := range req.Header["X-Forwarded-For"] {
+		for _, hop := range strings.Split(h, ",") {
+			if hop = strings.TrimSpace(hop); len(hop) > 0 {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	return hops
+}
+
+// frameLimiter reads the frames of a connection and fails on the first
+// message larger than max before any of its payload is read
+type frameLimiter struct {
+	r       io.Reader
+	max     int64
+	header  []byte
+	payload int64
+	message int64
+}
+
+func (l *frameLimiter) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	start := 0
+	for i := 0; i < n; {
+		if l.payload > 0 {
+			skip := int64(n - i)
+			if skip > l.payload {
+				skip = l.payload
+			}
+			l.payload -= skip
+			i += int(skip)
+			start = i
+			continue
+		}
+		l.header = append(l.header, p[i])
+		i++
+		length, ok := frameLength(l.header)
+		if !ok {
+			continue
+		}
+		fin, opcode := l.header[0]&0x80 != 0, l.header[0]&0x0f
+		l.header = l.header[:0]
+		if opcode < 0x8 {
+			// control frames do not belong to the message
+			l.message += length
+		}
+		if length < 0 || length > l.max || l.message > l.max {
+			// hold back the header of the frame too
+			return start, errMessageTooBig
+		}
+		if fin && opcode < 0x8 {
+			l.message = 0
+		}
+		l.payload = length
+		start = i
+	}
+	return n, err
+}
+
+// frameLength returns the payload length of the frame header,
+// ok is false until the header is complete
+func frameLength(header []byte) (length int64, ok bool) {
+	if len(header) < 2 {
+		return 0, false
+	}
+	fields := 0
+	switch b := header[1] & 0x7f; b {
+	case 126:
+		fields = 2
+	case 127:
+		fields = 8
+	default:
+		length = int64(b)
+	}
+	size := 2 + fields
+	if header[1]&0x80 != 0 {
+		// masking key
+		size += 4
+	}
+	if len(header) < size {
+		return 0, false
+	}
+	for _, b := range header[2 : 2+fields] {
+		length = length<<8 | int64(b)
+	}
+	return length, true
+}
+
+// frameLimitWriter hands the websocket package a connection whose
+// reads go through a frameLimiter
+type frameLimitWriter struct {
+	http.ResponseWriter
+	max int64
+}
+
+func (w frameLimitWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, buf, err := w.ResponseWriter.(http.Hijacker).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	r := bufio.NewReader(&frameLimiter{r: buf.Reader, max: w.max})
+	return conn, bufio.NewReadWriter(r, buf.Writer), nil
+}
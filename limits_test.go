@@ -0,0 +1,176 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"code.google.com/p/go.net/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// clientFrame encodes a masked frame like clients send them
+func clientFrame(fin bool, opcode byte, payload []byte) []byte {
+	b := opcode
+	if fin {
+		b |= 0x80
+	}
+	frame := []byte{b}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, 0x80|byte(n))
+	case n < 65536:
+		frame = append(frame, 0x80|126, byte(n>>8), byte(n))
+	default:
+		frame = append(frame, 0x80|127, 0, 0, 0, 0, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	frame = append(frame, 1, 2, 3, 4)
+	return append(frame, payload...)
+}
+
+// waitReleased waits for the connections of the broker to have ended
+func waitReleased(t *testing.T, b *broker) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		b.limits.Lock()
+		total := b.limits.total
+		b.limits.Unlock()
+		if total == 0 || time.Now().After(deadline) {
+			assert.Equal(t, 0, total)
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestFrameLimiter(t *testing.T) {
+	small := clientFrame(true, websocket.TextFrame, bytes.Repeat([]byte("a"), 100))
+	ping := clientFrame(true, websocket.PingFrame, []byte("ping"))
+	big := clientFrame(true, websocket.TextFrame, bytes.Repeat([]byte("a"), 300))
+
+	stream := append(append(append([]byte{}, small...), ping...), small...)
+	data, err := ioutil.ReadAll(&frameLimiter{r: iotest.OneByteReader(bytes.NewReader(stream)), max: 200})
+	assert.Nil(t, err)
+	assert.Equal(t, stream, data, "Frames within the limit must pass unchanged")
+
+	data, err = ioutil.ReadAll(&frameLimiter{r: bytes.NewReader(append(append([]byte{}, small...), big...)), max: 200})
+	assert.Equal(t, errMessageTooBig, err)
+	assert.Equal(t, small, data, "Nothing of a frame over the limit must be read")
+
+	fragments := append(clientFrame(false, websocket.TextFrame, bytes.Repeat([]byte("a"), 120)), ping...)
+	fragments = append(fragments, clientFrame(true, websocket.ContinuationFrame, bytes.Repeat([]byte("a"), 120))...)
+	_, err = ioutil.ReadAll(&frameLimiter{r: bytes.NewReader(fragments), max: 200})
+	assert.Equal(t, errMessageTooBig, err, "Fragmented messages must be limited as a whole")
+}
+
+func TestFrameLength(t *testing.T) {
+	for _, size := range []int{0, 125, 126, 65535, 65536} {
+		frame := clientFrame(true, websocket.BinaryFrame, make([]byte, size))
+		length, ok := frameLength(frame[:len(frame)-size-1])
+		assert.False(t, ok, "Incomplete headers must not be parsed")
+		length, ok = frameLength(frame[:len(frame)-size])
+		assert.True(t, ok)
+		assert.Equal(t, int64(size), length)
+	}
+}
+
+func TestConnLimits(t *testing.T) {
+	l := newConnLimits(3, 1, 2)
+	assert.Empty(t, l.acquire("10.0.0.1"))
+	assert.Empty(t, l.acquire("10.0.0.1"))
+	assert.Equal(t, limitIP, l.acquire("10.0.0.1"))
+	assert.Empty(t, l.acquire("10.0.0.2"))
+	assert.Equal(t, limitTotal, l.acquire("10.0.0.3"))
+	l.release("10.0.0.1")
+	assert.Empty(t, l.acquire("10.0.0.3"), "Released connections must not be counted")
+	assert.Equal(t, map[string]int{"10.0.0.1": 1, "10.0.0.2": 1, "10.0.0.3": 1}, l.ips)
+
+	assert.True(t, l.acquireDevice("dev1"))
+	assert.False(t, l.acquireDevice("dev1"))
+	assert.True(t, l.acquireDevice(""), "Unknown devices must only be limited by address")
+	assert.True(t, l.acquireDevice(""))
+	assert.True(t, l.acquireDevice("dev2"))
+	l.releaseDevice("dev1")
+	l.releaseDevice("")
+	assert.True(t, l.acquireDevice("dev1"), "Released devices must not be counted")
+	assert.Equal(t, map[string]int{"dev1": 1, "dev2": 1}, l.devices)
+}
+
+func TestRemoteIP(t *testing.T) {
+	req, _ := http.NewRequest("GET", "http://localhost/ws", nil)
+	req.RemoteAddr = "10.0.0.1:5000"
+	assert.Equal(t, "10.0.0.1", remoteIP(req, 1), "Requests without the header must use the connection")
+
+	req.Header.Add("X-Forwarded-For", "1.1.1.1, 2.2.2.2")
+	req.Header.Add("X-Forwarded-For", "3.3.3.3")
+	assert.Equal(t, "10.0.0.1", remoteIP(req, 0), "The header must be ignored unless proxies are trusted")
+	assert.Equal(t, "3.3.3.3", remoteIP(req, 1))
+	assert.Equal(t, "2.2.2.2", remoteIP(req, 2))
+	assert.Equal(t, "1.1.1.1", remoteIP(req, 5))
+}
+
+func TestBroker_Limits(t *testing.T) {
+	pub = &ackPublisher{}
+	b := newBroker()
+	b.authVal = deviceAuth{}
+	b.limits = newConnLimits(0, 1, 0)
+	b.maxMessage = 1024
+	ts, _ := serveTestBroker(t, b)
+	defer ts.Close()
+	ts.Config.Handler = http.HandlerFunc(b.onRequest)
+
+	ws := dialTestServer(t, ts, "/?device=dev1")
+	defer ws.Close()
+	c := waitClients(t, b, 1)[0]
+
+	// the device is limited at the upgrade too
+	rejected := connectionsRejected.with(limitDevice).value()
+	resp, err := http.Get(ts.URL + "/?device=dev1")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode, "Connections over the device limit must be rejected")
+	resp.Body.Close()
+	assert.Equal(t, rejected+1, connectionsRejected.with(limitDevice).value())
+	var m string
+
+	assert.Nil(t, websocket.Message.Send(ws, strings.Repeat("a", 512)))
+	assert.Nil(t, websocket.Message.Send(ws, strings.Repeat("a", 2048)))
+	ws.SetDeadline(time.Now().Add(5 * time.Second))
+	assert.Equal(t, io.EOF, websocket.Message.Receive(ws, &m), "Messages over the limit must close the connection")
+	waitReleased(t, b)
+	assert.Equal(t, int32(closeMessageTooBig), c.closeCode)
+	assert.Equal(t, int64(1), c.received)
+
+	ws = dialTestServer(t, ts, "/?device=dev1")
+	waitClients(t, b, 1)
+
+	// addresses are limited at the upgrade
+	b.limits.Lock()
+	b.limits.maxIP = 1
+	b.limits.Unlock()
+	resp, err = http.Get(ts.URL + "/?device=dev2")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode, "Connections over the limit must be rejected")
+	resp.Body.Close()
+	ws.Close()
+	waitReleased(t, b)
+}
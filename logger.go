@@ -0,0 +1,261 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// log levels in increasing severity
+const (
+	levelDebug int32 = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+// log body modes
+const (
+	bodyRedact = "redact"
+	bodySample = "sample"
+	bodyFull   = "full"
+)
+
+const defaultLogSampleRate = 100
+
+var (
+	levelNames = []string{"debug", "info", "warn", "error"}
+
+	logLevel  = levelInfo
+	logJSON   bool
+	logBodies        = bodyRedact
+	logSample uint64 = defaultLogSampleRate
+	logCount  uint64
+	logMu     sync.Mutex
+	logOut    io.Writer = os.Stdout
+
+	logger = &Logger{}
+)
+
+// Logger writes leveled log entries carrying its correlation fields
+type Logger struct {
+	fields []logField
+}
+
+type logField struct {
+	key   string
+	value interface{}
+}
+
+// With returns a logger adding the field to every entry or replacing it,
+// empty values are left out so unknown ids do not show up as blanks
+func (l *Logger) With(key string, value interface{}) *Logger {
+	if s, ok := value.(string); ok && len(s) == 0 {
+		return l
+	}
+	fields := make([]logField, 0, len(l.fields)+1)
+	for _, f := range l.fields {
+		if f.key != key {
+			fields = append(fields, f)
+		}
+	}
+	return &Logger{fields: append(fields, logField{key, value})}
+}
+
+// Message returns a logger correlating its entries with the message
+func (l *Logger) Message(msg *Message) *Logger {
+	return l.With("msg_id", msg.ID).With("device", msg.Device)
+}
+
+// Debugf logs at debug level
+func (l *Logger) Debugf(format string, v ...interface{}) { l.output(levelDebug, format, v...) }
+
+// Infof logs at info level
+func (l *Logger) Infof(format string, v ...interface{}) { l.output(levelInfo, format, v...) }
+
+// Warnf logs at warn level
+func (l *Logger) Warnf(format string, v ...interface{}) { l.output(levelWarn, format, v...) }
+
+// Errorf logs at error level
+func (l *Logger) Errorf(format string, v ...interface{}) { l.output(levelError, format, v...) }
+
+// Fatalf logs at error level and exits
+func (l *Logger) Fatalf(format string, v ...interface{}) {
+	l.output(levelError, format, v...)
+	os.Exit(1)
+}
+
+// Enabled tells whether entries of the level are written
+func (l *Logger) Enabled(level int32) bool {
+	return level >= atomic.LoadInt32(&logLevel)
+}
+
+func (l *Logger) output(level int32, format string, v ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	caller := "???:0"
+	if _, file, line, ok := runtime.Caller(2); ok {
+		caller = fmt.Sprintf("%s:%d", filepath.Base(file), line)
+	}
+	msg := fmt.Sprintf(format, v...)
+
+	var buf bytes.Buffer
+	if logJSON {
+		buf.WriteString(`{"time":`)
+		writeJSON(&buf, time.Now().UTC().Format(time.RFC3339Nano))
+		buf.WriteString(`,"level":`)
+		writeJSON(&buf, levelNames[level])
+		buf.WriteString(`,"caller":`)
+		writeJSON(&buf, caller)
+		buf.WriteString(`,"msg":`)
+		writeJSON(&buf, msg)
+		for _, f := range l.fields {
+			buf.WriteByte(',')
+			writeJSON(&buf, f.key)
+			buf.WriteByte(':')
+			writeJSON(&buf, f.value)
+		}
+		buf.WriteString("}\n")
+	} else {
+		fmt.Fprintf(&buf, "%s: [%s] %s", caller, levelNames[level], msg)
+		for _, f := range l.fields {
+			fmt.Fprintf(&buf, " %s=%v", f.key, f.value)
+		}
+		buf.WriteByte('\n')
+	}
+
+	logMu.Lock()
+	logOut.Write(buf.Bytes())
+	logMu.Unlock()
+}
+
+func writeJSON(buf *bytes.Buffer, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		b, _ = json.Marshal(fmt.Sprint(v))
+	}
+	buf.Write(b)
+}
+
+// logBody returns the message body as it may be logged, redacted unless
+// bodies are logged in full or the message is sampled
+func logBody(body string) string {
+	switch logBodies {
+	case bodyFull:
+		return body
+	case bodySample:
+		if atomic.AddUint64(&logCount, 1)%logSample == 0 {
+			return body
+		}
+	}
+	return fmt.Sprintf("<%d bytes>", len(body))
+}
+
+// parseLevel returns the level by its name
+func parseLevel(name string) (int32, error) {
+	for i, n := range levelNames {
+		if strings.EqualFold(n, name) {
+			return int32(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level: %s", name)
+}
+
+// setLogLevel changes the level of all the loggers at runtime
+func setLogLevel(name string) error {
+	level, err := parseLevel(name)
+	if err != nil {
+		return err
+	}
+	atomic.StoreInt32(&logLevel, level)
+	return nil
+}
+
+// showLogLevel responds with the log level, PUT or POST with a level
+// parameter changes it
+func showLogLevel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+	case "PUT", "POST":
+		if err := setLogLevel(r.FormValue("level")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		logger.Infof("log level set to %s", r.FormValue("level"))
+	default:
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"level": levelNames[atomic.LoadInt32(&logLevel)],
+	})
+}
+
+// configLogging applies the environment and the defaults to the loggers
+func configLogging(c *LogConfig, trace bool) {
+	SetWithStringEnvVar("GATEWAY_LOG_LEVEL", &c.Level)
+	SetWithStringEnvVar("GATEWAY_LOG_FORMAT", &c.Format)
+	SetWithStringEnvVar("GATEWAY_LOG_BODIES", &c.Bodies)
+	c.SampleRate = GetEnvVarAsInt("GATEWAY_LOG_SAMPLE_RATE", c.SampleRate)
+
+	if len(c.Level) == 0 {
+		c.Level = levelNames[levelInfo]
+		if trace {
+			c.Level = levelNames[levelDebug]
+		}
+	}
+	if err := setLogLevel(c.Level); err != nil {
+		log.Panicf("Invalid log configuration: %v", err)
+	}
+
+	switch strings.ToLower(c.Format) {
+	case "", "text":
+		logJSON = false
+	case "json":
+		logJSON = true
+	default:
+		log.Panicf("Invalid log format: %v", c.Format)
+	}
+
+	c.Bodies = strings.ToLower(c.Bodies)
+	switch c.Bodies {
+	case "":
+		c.Bodies = bodyRedact
+	case bodyRedact, bodySample, bodyFull:
+	default:
+		log.Panicf("Invalid log bodies mode: %v", c.Bodies)
+	}
+	logBodies = c.Bodies
+
+	if c.SampleRate <= 0 {
+		c.SampleRate = defaultLogSampleRate
+	}
+	logSample = uint64(c.SampleRate)
+}
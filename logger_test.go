@@ -0,0 +1,130 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// captureLogs redirects the log entries to a buffer until the returned
+// function restores the output and the settings
+func captureLogs(asJSON bool) (*bytes.Buffer, func()) {
+	buf := &bytes.Buffer{}
+	level, format := atomic.LoadInt32(&logLevel), logJSON
+	logMu.Lock()
+	logOut, logJSON = buf, asJSON
+	logMu.Unlock()
+	return buf, func() {
+		logMu.Lock()
+		logOut, logJSON = os.Stdout, format
+		logMu.Unlock()
+		atomic.StoreInt32(&logLevel, level)
+	}
+}
+
+func TestLogger_JSON(t *testing.T) {
+	buf, restore := captureLogs(true)
+	defer restore()
+
+	msg := NewMessage("secret")
+	msg.Device = "dev1"
+	(&Logger{}).With("gateway", "g1").With("conn", 7).Message(msg).Errorf("failed %d", 1)
+
+	var entry map[string]interface{}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "error", entry["level"])
+	assert.Equal(t, "failed 1", entry["msg"])
+	assert.Equal(t, "g1", entry["gateway"])
+	assert.Equal(t, float64(7), entry["conn"])
+	assert.Equal(t, "dev1", entry["device"])
+	assert.Equal(t, msg.ID, entry["msg_id"])
+	assert.True(t, strings.HasPrefix(entry["caller"].(string), "logger_test.go:"))
+}
+
+func TestLogger_Level(t *testing.T) {
+	buf, restore := captureLogs(false)
+	defer restore()
+
+	assert.Nil(t, setLogLevel("warn"))
+	l := &Logger{}
+	l.Infof("hidden")
+	l.With("device", "").Warnf("shown")
+	assert.Equal(t, "[warn] shown\n", buf.String()[strings.Index(buf.String(), "[warn]"):],
+		"Entries below the level and empty fields must be left out")
+	assert.NotNil(t, setLogLevel("verbose"))
+
+	buf.Reset()
+	assert.Nil(t, setLogLevel("debug"))
+	l.With("conn", 1).With("conn", 2).Debugf("replaced")
+	assert.Contains(t, buf.String(), "[debug] replaced conn=2\n", "Fields must be replaced, not repeated")
+}
+
+func TestLogBody(t *testing.T) {
+	defer func(mode string, rate uint64) { logBodies, logSample = mode, rate }(logBodies, logSample)
+
+	logBodies = bodyRedact
+	assert.Equal(t, "<6 bytes>", logBody("secret"))
+
+	logBodies, logSample = bodySample, 2
+	sampled := 0
+	for i := 0; i < 10; i++ {
+		if logBody("secret") == "secret" {
+			sampled++
+		}
+	}
+	assert.Equal(t, 5, sampled, "One body in sample rate must be logged")
+
+	logBodies = bodyFull
+	assert.Equal(t, "secret", logBody("secret"))
+}
+
+func TestShowLogLevel(t *testing.T) {
+	_, restore := captureLogs(false)
+	defer restore()
+
+	w := httptest.NewRecorder()
+	showLogLevel(w, httptest.NewRequest("PUT", "/loglevel?level=error", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "{\"level\":\"error\"}\n", w.Body.String())
+	assert.False(t, logger.Enabled(levelWarn))
+
+	w = httptest.NewRecorder()
+	showLogLevel(w, httptest.NewRequest("PUT", "/loglevel?level=loud", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	showLogLevel(w, httptest.NewRequest("GET", "/loglevel", nil))
+	assert.Equal(t, "{\"level\":\"error\"}\n", w.Body.String())
+}
+
+func TestConfigLogging_Trace(t *testing.T) {
+	_, restore := captureLogs(false)
+	defer restore()
+
+	var c Config
+	loadConfig("defaults.json", &c)
+	configLogging(&c.Log, true)
+	assert.True(t, logger.Enabled(levelDebug), "Trace must select debug unless a level is set")
+}
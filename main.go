@@ -20,6 +20,9 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func init() {
@@ -37,12 +40,62 @@ func showHome(w http.ResponseWriter, r *http.Request) {
 }
 
 func main() {
-	log.Printf("starting...")
+	logger.Infof("starting...")
 	queueInit()
 	b := newBroker()
 	go b.listen()
+	if args.Server.RateLimit.Enabled {
+		logger.Infof("Limiting the rate of the clients, %s when over the limits", args.Server.RateLimit.Action)
+		rates = newRateLimits(&args.Server.RateLimit)
+		if len(args.Server.RateLimit.UsageFile) > 0 {
+			logger.Infof("Saving the usage to %s", args.Server.RateLimit.UsageFile)
+			go rates.persist()
+		}
+	}
+	if args.Pub.Downlink.Enabled {
+		logger.Infof("Delivering commands from %s", args.Pub.Downlink.Topic)
+		downlink = newDownlink(b, args.ID, &args.Pub)
+	}
 	a := fmt.Sprintf("%s:%d", args.Server.Host, args.Server.Port)
-	log.Printf("server: %s", a)
+	logger.Infof("server: %s", a)
 	http.HandleFunc("/", showHome)
-	log.Fatal(http.ListenAndServe(a, nil))
+	http.HandleFunc("/healthz", showHealth)
+	http.HandleFunc("/readyz", showReadiness)
+	http.HandleFunc("/metrics", showMetrics)
+	if args.Server.Ingest.Enabled {
+		logger.Infof("ingest: %s", args.Server.Ingest.Path)
+		http.HandleFunc(args.Server.Ingest.Path, b.onIngest)
+	}
+
+	var admin *http.Server
+	if args.Admin.Enabled {
+		h := newAdminHandler(b, args.Admin.Token)
+		if args.Admin.Port == 0 {
+			http.Handle("/admin/", h)
+		} else {
+			admin = &http.Server{Addr: fmt.Sprintf("%s:%d", args.Admin.Host, args.Admin.Port), Handler: h}
+			logger.Infof("admin: %s", admin.Addr)
+			go func() {
+				if err := admin.ListenAndServe(); err != http.ErrServerClosed {
+					logger.Fatalf("%v", err)
+				}
+			}()
+		}
+	}
+
+	srv := &http.Server{Addr: a}
+	go func() {
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			logger.Fatalf("%v", err)
+		}
+	}()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGTERM, syscall.SIGINT)
+	logger.Infof("received %v, shutting down...", <-sig)
+	shutdown(srv, b, time.Duration(args.Server.ShutdownTimeout)*time.Second)
+	if admin != nil {
+		admin.Close()
+	}
+	logger.Infof("stopped")
 }
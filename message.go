@@ -17,7 +17,6 @@ package main
 
 import (
 	"encoding/json"
-	"log"
 	"time"
 
 	"code.google.com/p/go-uuid/uuid"
@@ -43,13 +42,58 @@ type Message struct {
 
 	// Body represents message content
 	Body string `json:"body"`
+
+	// Device is the id of the authenticated device which sent the message
+	Device string `json:"-"`
+
+	// Auth is the authentication method the device was admitted with
+	Auth string `json:"-"`
+
+	// Trace is the W3C traceparent of the span publishing the message
+	Trace string `json:"-"`
+
+	// delivered is called once the backend confirmed or failed the delivery
+	delivered func(err error)
+
+	// spooled is set on the messages the spool retries when the backend
+	// fails them, they are not dead-lettered then
+	spooled bool
+}
+
+// Delivered reports the outcome of the delivery to the client waiting for it
+func (m *Message) Delivered(err error) {
+	if m.delivered != nil {
+		m.delivered(err)
+	}
+}
+
+// Failed reports a delivery the backend failed after the attempts, the
+// message is dead-lettered unless the spool retries it
+func (m *Message) Failed(err error, attempts int) {
+	if !m.spooled {
+		deadLetters.Send(m, stageDelivery, err, attempts)
+	}
+	m.Delivered(err)
+}
+
+// ContentType tells whether the message content is JSON or plain text
+func (m *Message) ContentType() string {
+	if json.Valid([]byte(m.Body)) {
+		return "application/json"
+	}
+	return "text/plain; charset=utf-8"
 }
 
 // ToBytes converts content of the current message into byte array
 func (m *Message) ToBytes() []byte {
 	b, err := json.Marshal(m)
 	if err != nil {
-		log.Printf("unable to marshal: %v", err.Error())
+		logger.Message(m).Errorf("unable to marshal: %v", err)
 	}
 	return b
 }
+
+// String returns the JSON representation of the current message
+func (m *Message) String() string {
+	return string(m.ToBytes())
+}
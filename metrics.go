@@ -0,0 +1,292 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	metricCounter   = "counter"
+	metricGauge     = "gauge"
+	metricHistogram = "histogram"
+)
+
+var (
+	metrics = &metricsRegistry{families: make(map[string]*metricFamily)}
+
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+	// latencyBuckets are the upper bounds in seconds of the latency histograms
+	latencyBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+	connectionsActive  = newGauge("gateway_connections_active", "Number of connected clients.")
+	connectionAttempts = newCounter("gateway_connection_attempts_total", "Number of WebSocket connection attempts.", "method")
+	authFailures       = newCounter("gateway_auth_failures_total", "Number of rejected connection attempts.", "method", "reason")
+	messagesReceived   = newCounter("gateway_messages_received_total", "Number of messages received from clients.")
+	bytesReceived      = newCounter("gateway_received_bytes_total", "Number of message bytes received from clients.")
+	publishSuccesses   = newCounter("gateway_published_total", "Number of messages delivered to the backend.", "backend", "topic")
+	publishFailures    = newCounter("gateway_publish_failures_total", "Number of messages the backend failed to take.", "backend", "topic")
+	publishLatency     = newHistogram("gateway_publish_latency_seconds", "Time from receiving a message to its delivery.", latencyBuckets, "backend", "topic")
+)
+
+func init() {
+	newGaugeFunc("gateway_messages_inflight", "Number of received messages not yet delivered.", func() float64 {
+		return float64(atomic.LoadInt64(&inflight))
+	})
+}
+
+// recordDelivery counts the delivery result of the message and observes
+// the time it took since the message was received
+func recordDelivery(backend, topic string, msg *Message, err error) {
+	if err != nil {
+		publishFailures.with(backend, topic).inc()
+		return
+	}
+	publishSuccesses.with(backend, topic).inc()
+	publishLatency.observe(time.Since(msg.On).Seconds(), backend, topic)
+}
+
+// metricsRegistry holds the metric families exposed on /metrics
+type metricsRegistry struct {
+	mu       sync.Mutex
+	families map[string]*metricFamily
+}
+
+// register adds the family, a family registered again under the same
+// name replaces the previous one
+func (r *metricsRegistry) register(f *metricFamily) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.families[f.name] = f
+}
+
+// metricFamily is a named metric with a series per combination of labels,
+// or with a collect function producing the samples when it is exposed
+type metricFamily struct {
+	name    string
+	help    string
+	typ     string
+	labels  []string
+	buckets []float64
+	collect func() []metricSample
+
+	mu     sync.Mutex
+	series map[string]*metricSeries
+}
+
+// metricSample is a single value of a collected family
+type metricSample struct {
+	labels []string
+	value  float64
+}
+
+// metricSeries is the value of a family for one combination of labels
+type metricSeries struct {
+	labels []string
+	bits   uint64
+
+	mu     sync.Mutex
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newFamily(name, help, typ string, labels []string) *metricFamily {
+	f := &metricFamily{
+		name:   name,
+		help:   help,
+		typ:    typ,
+		labels: labels,
+		series: make(map[string]*metricSeries),
+	}
+	metrics.register(f)
+	return f
+}
+
+func newCounter(name, help string, labels ...string) *metricFamily {
+	return newFamily(name, help, metricCounter, labels)
+}
+
+func newGauge(name, help string, labels ...string) *metricFamily {
+	return newFamily(name, help, metricGauge, labels)
+}
+
+func newHistogram(name, help string, buckets []float64, labels ...string) *metricFamily {
+	f := newFamily(name, help, metricHistogram, labels)
+	f.buckets = buckets
+	return f
+}
+
+// newGaugeFunc registers a gauge reading its value when it is exposed
+func newGaugeFunc(name, help string, value func() float64) {
+	newCollector(name, help, metricGauge, nil, func() []metricSample {
+		return []metricSample{{value: value()}}
+	})
+}
+
+// newCollector registers a family whose samples are collected when exposed
+func newCollector(name, help, typ string, labels []string, collect func() []metricSample) {
+	f := newFamily(name, help, typ, labels)
+	f.collect = collect
+}
+
+// with returns the series for the label values, creating it when needed
+func (f *metricFamily) with(values ...string) *metricSeries {
+	key := strings.Join(values, "\xff")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, ok := f.series[key]
+	if !ok {
+		s = &metricSeries{labels: values}
+		if f.typ == metricHistogram {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+func (s *metricSeries) inc() { s.add(1) }
+
+func (s *metricSeries) add(v float64) {
+	for {
+		old := atomic.LoadUint64(&s.bits)
+		if atomic.CompareAndSwapUint64(&s.bits, old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+func (s *metricSeries) set(v float64) {
+	atomic.StoreUint64(&s.bits, math.Float64bits(v))
+}
+
+func (s *metricSeries) value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&s.bits))
+}
+
+// observe adds the value to the histogram buckets it falls in
+func (s *metricSeries) observe(buckets []float64, v float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, upper := range buckets {
+		if v <= upper {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+func (f *metricFamily) observe(v float64, values ...string) {
+	f.with(values...).observe(f.buckets, v)
+}
+
+// write writes the family in the Prometheus text exposition format
+func (f *metricFamily) write(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, f.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.typ)
+
+	if f.collect != nil {
+		for _, s := range f.collect() {
+			writeSample(w, f.name, f.labels, s.labels, "", s.value)
+		}
+		return
+	}
+
+	f.mu.Lock()
+	series := make([]*metricSeries, 0, len(f.series))
+	for _, s := range f.series {
+		series = append(series, s)
+	}
+	f.mu.Unlock()
+	sort.Slice(series, func(i, j int) bool {
+		return strings.Join(series[i].labels, "\xff") < strings.Join(series[j].labels, "\xff")
+	})
+
+	if len(series) == 0 && len(f.labels) == 0 {
+		series = append(series, f.with())
+	}
+	for _, s := range series {
+		if f.typ != metricHistogram {
+			writeSample(w, f.name, f.labels, s.labels, "", s.value())
+			continue
+		}
+		s.mu.Lock()
+		for i, upper := range f.buckets {
+			writeSample(w, f.name+"_bucket", f.labels, s.labels,
+				strconv.FormatFloat(upper, 'g', -1, 64), float64(s.counts[i]))
+		}
+		writeSample(w, f.name+"_bucket", f.labels, s.labels, "+Inf", float64(s.count))
+		writeSample(w, f.name+"_sum", f.labels, s.labels, "", s.sum)
+		writeSample(w, f.name+"_count", f.labels, s.labels, "", float64(s.count))
+		s.mu.Unlock()
+	}
+}
+
+func writeSample(w *bufio.Writer, name string, labels, values []string, le string, v float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || len(le) > 0 {
+		w.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", l, labelEscaper.Replace(values[i]))
+		}
+		if len(le) > 0 {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "le=\"%s\"", le)
+		}
+		w.WriteByte('}')
+	}
+	fmt.Fprintf(w, " %s\n", strconv.FormatFloat(v, 'g', -1, 64))
+}
+
+// showMetrics exposes the metrics in the Prometheus text format
+func showMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
+
+	metrics.mu.Lock()
+	families := make([]*metricFamily, 0, len(metrics.families))
+	for _, f := range metrics.families {
+		families = append(families, f)
+	}
+	metrics.mu.Unlock()
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		f.write(bw)
+	}
+	bw.Flush()
+}
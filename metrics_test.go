@@ -0,0 +1,79 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getMetrics(t *testing.T) string {
+	w := httptest.NewRecorder()
+	showMetrics(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", w.Header().Get("Content-Type"))
+	return w.Body.String()
+}
+
+func TestShowMetrics(t *testing.T) {
+	c := newCounter("test_events_total", "Number of test events.", "kind")
+	c.with("a").inc()
+	c.with(`b"\`).add(2)
+	h := newHistogram("test_latency_seconds", "Test latency.", []float64{0.1, 1}, "kind")
+	h.observe(0.5, "a")
+	h.observe(2, "a")
+	newGaugeFunc("test_depth", "Test depth.", func() float64 { return 7 })
+
+	body := getMetrics(t)
+	assert.Contains(t, body, "# HELP test_events_total Number of test events.\n# TYPE test_events_total counter\n")
+	assert.Contains(t, body, "test_events_total{kind=\"a\"} 1\n")
+	assert.Contains(t, body, "test_events_total{kind=\"b\\\"\\\\\"} 2\n", "Label values must be escaped")
+	assert.Contains(t, body, "# TYPE test_latency_seconds histogram\n"+
+		"test_latency_seconds_bucket{kind=\"a\",le=\"0.1\"} 0\n"+
+		"test_latency_seconds_bucket{kind=\"a\",le=\"1\"} 1\n"+
+		"test_latency_seconds_bucket{kind=\"a\",le=\"+Inf\"} 2\n"+
+		"test_latency_seconds_sum{kind=\"a\"} 2.5\n"+
+		"test_latency_seconds_count{kind=\"a\"} 2\n")
+	assert.Contains(t, body, "# TYPE test_depth gauge\ntest_depth 7\n")
+	assert.Contains(t, body, "gateway_connections_active ", "Unlabeled metrics must be exposed before their first update")
+}
+
+func TestRecordDelivery(t *testing.T) {
+	msg := NewMessage("one")
+	msg.On = msg.On.Add(-time.Second)
+	recordDelivery("test", "delivered", msg, nil)
+	recordDelivery("test", "delivered", msg, errors.New("boom"))
+
+	assert.Equal(t, float64(1), publishSuccesses.with("test", "delivered").value())
+	assert.Equal(t, float64(1), publishFailures.with("test", "delivered").value())
+	body := getMetrics(t)
+	assert.Contains(t, body, "gateway_publish_latency_seconds_bucket{backend=\"test\",topic=\"delivered\",le=\"0.5\"} 0\n")
+	assert.Contains(t, body, "gateway_publish_latency_seconds_bucket{backend=\"test\",topic=\"delivered\",le=\"1\"} 0\n")
+	assert.Contains(t, body, "gateway_publish_latency_seconds_count{backend=\"test\",topic=\"delivered\"} 1\n")
+}
+
+func TestAuthFailureReason(t *testing.T) {
+	req := httptest.NewRequest("GET", "/ws", nil)
+	assert.Equal(t, "missing_credentials", authFailureReason(req))
+	req.Header.Set("Authorization", "Bearer x")
+	assert.Equal(t, "invalid_credentials", authFailureReason(req))
+	assert.Equal(t, "invalid_credentials", authFailureReason(httptest.NewRequest("GET", "/ws?access_token=x", nil)))
+}
@@ -0,0 +1,248 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Overflow policies of the connection buffers
+const (
+	overflowBlock      = "block"
+	overflowDropOldest = "drop_oldest"
+	overflowDropNewest = "drop_newest"
+	overflowDisconnect = "disconnect"
+)
+
+const (
+	defaultPipelineBuffer = 64
+	defaultSendBuffer     = channelBufSize
+
+	// stageOverflow is where the dead letters of the dropped messages come from
+	stageOverflow = "overflow"
+
+	// readyQueue bounds how many connections can wait for the workers,
+	// it is shared out among them and connections beyond wait for their
+	// turn to be queued
+	readyQueue = 1 << 16
+
+	// workerBatch is how many messages a worker takes from a connection
+	// before it moves on to the next one
+	workerBatch = 16
+)
+
+var (
+	errBufferFull     = errors.New("connection buffer is full")
+	errPipelineClosed = errors.New("gateway is shutting down")
+
+	pipelineOverflows = newCounter("gateway_pipeline_overflows_total",
+		"Messages received while the connection buffer was full by policy.", "policy")
+)
+
+// defaultPipelineWorkers hands the messages on with two workers per CPU
+func defaultPipelineWorkers() int {
+	return 2 * runtime.NumCPU()
+}
+
+// lane is a worker, the messages of the outboxes pinned to a lane are
+// handed on to the publisher in the order they came
+type lane struct {
+	ready chan *outbox
+}
+
+// outbox buffers the messages of a connection until the worker of its
+// lane hands them on, scheduled is set while the outbox is queued or
+// being worked on
+type outbox struct {
+	queue     chan *Message
+	lane      *lane
+	scheduled int32
+}
+
+// pipeline hands the messages of all of the connections to the publisher
+// with a fixed number of workers, whatever the number of connections, the
+// workers share the one listener of the publisher
+type pipeline struct {
+	pub      Publisher
+	buffer   int
+	overflow string
+	lanes    []*lane
+	in       chan *Message
+	next     uint32
+	start    sync.Once
+	workers  sync.WaitGroup
+	closed   int32
+	done     chan bool
+}
+
+func newPipeline(p Publisher, conf *PipelineConfig) *pipeline {
+	pl := &pipeline{
+		pub:      p,
+		buffer:   conf.Buffer,
+		overflow: conf.Overflow,
+		lanes:    make([]*lane, conf.Workers),
+		in:       make(chan *Message),
+		done:     make(chan bool),
+	}
+	for i := range pl.lanes {
+		pl.lanes[i] = &lane{ready: make(chan *outbox, readyQueue/len(pl.lanes))}
+	}
+	return pl
+}
+
+// newOutbox returns the buffer of a new connection, the connections are
+// pinned to the lanes in turn
+func (p *pipeline) newOutbox() *outbox {
+	n := atomic.AddUint32(&p.next, 1)
+	return &outbox{
+		queue: make(chan *Message, p.buffer),
+		lane:  p.lanes[n%uint32(len(p.lanes))],
+	}
+}
+
+// run starts the publisher listener and the workers, they are only
+// started once there are messages so brokers without any are free
+func (p *pipeline) run() {
+	go p.pub.Start(p.in)
+	for _, l := range p.lanes {
+		p.workers.Add(1)
+		go p.work(l)
+	}
+}
+
+// close stops the workers once they handed on the queued outboxes and then
+// the publisher listener, messages pushed afterwards are failed
+func (p *pipeline) close() {
+	if !atomic.CompareAndSwapInt32(&p.closed, 0, 1) {
+		return
+	}
+	// a pipeline not started yet is not started anymore
+	p.start.Do(func() {})
+	close(p.done)
+	p.workers.Wait()
+	close(p.in)
+}
+
+// push buffers the message of the connection, a full buffer is handled
+// according to the overflow policy, an error tells the connection is to be
+// disconnected: errBufferFull or errPipelineClosed once the pipeline closed
+func (p *pipeline) push(o *outbox, m *Message) (err error) {
+	if atomic.LoadInt32(&p.closed) == 1 {
+		m.Delivered(errPipelineClosed)
+		return errPipelineClosed
+	}
+	p.start.Do(p.run)
+	if p.overflow == overflowBlock {
+		o.queue <- m
+	} else {
+		select {
+		case o.queue <- m:
+		default:
+			pipelineOverflows.with(p.overflow).inc()
+			switch p.overflow {
+			case overflowDropOldest:
+				// a worker may have made room meanwhile
+				select {
+				case old := <-o.queue:
+					p.drop(old)
+				default:
+				}
+				select {
+				case o.queue <- m:
+				default:
+					p.drop(m)
+				}
+			case overflowDisconnect:
+				p.drop(m)
+				err = errBufferFull
+			default:
+				p.drop(m)
+			}
+		}
+	}
+	p.schedule(o)
+	return err
+}
+
+// drop reports the message as failed
+func (p *pipeline) drop(m *Message) {
+	logger.Message(m).Warnf("dropped: %v", errBufferFull)
+	deadLetters.Send(m, stageOverflow, errBufferFull, 0)
+	m.Delivered(errBufferFull)
+}
+
+// schedule queues the outbox for the worker of its lane unless it is already
+func (p *pipeline) schedule(o *outbox) {
+	if atomic.CompareAndSwapInt32(&o.scheduled, 0, 1) {
+		o.lane.ready <- o
+	}
+}
+
+// work hands the messages of the queued outboxes of the lane to the
+// publisher, a batch at a time so that busy connections do not hold the
+// others up
+func (p *pipeline) work(l *lane) {
+	defer p.workers.Done()
+	for {
+		select {
+		case o := <-l.ready:
+			for p.process(o) {
+			}
+		case <-p.done:
+			// the outboxes queued before the close are handed on
+			for {
+				select {
+				case o := <-l.ready:
+					for p.process(o) {
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// process hands a batch of the outbox on, it tells whether the outbox
+// still has messages and could not be queued again
+func (p *pipeline) process(o *outbox) bool {
+	p.drain(o)
+	atomic.StoreInt32(&o.scheduled, 0)
+	if len(o.queue) == 0 || !atomic.CompareAndSwapInt32(&o.scheduled, 0, 1) {
+		return false
+	}
+	select {
+	case o.lane.ready <- o:
+		return false
+	default:
+		// the queue is full, keep on with this outbox
+		return true
+	}
+}
+
+func (p *pipeline) drain(o *outbox) {
+	for i := 0; i < workerBatch; i++ {
+		select {
+		case m := <-o.queue:
+			p.in <- m
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,280 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"code.google.com/p/go.net/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// orderPublisher records the bodies in the order they are published, its
+// listeners take their time so that they interleave
+type orderPublisher struct {
+	mu     sync.Mutex
+	bodies []string
+}
+
+func (p *orderPublisher) Config(clientID string, args *PubConfig) {}
+
+func (p *orderPublisher) Start(in <-chan *Message) {
+	for m := range in {
+		time.Sleep(time.Duration(rand.Intn(100)) * time.Microsecond)
+		p.mu.Lock()
+		p.bodies = append(p.bodies, m.Body)
+		p.mu.Unlock()
+		m.Delivered(nil)
+	}
+}
+
+// stoppedPipeline returns a pipeline whose workers never start
+func stoppedPipeline(buffer int, overflow string) *pipeline {
+	p := newPipeline(&ackPublisher{}, &PipelineConfig{Workers: 1, Buffer: buffer, Overflow: overflow})
+	p.start.Do(func() {})
+	return p
+}
+
+// resultMessage returns a message reporting its delivery to results
+func resultMessage(body string, results chan<- error) *Message {
+	m := NewMessage(body)
+	m.delivered = func(err error) { results <- err }
+	return m
+}
+
+func TestPipeline_Overflow(t *testing.T) {
+	for _, test := range []struct {
+		overflow string
+		dropped  string
+		kept     []string
+		err      error
+	}{
+		{overflowDropNewest, "3", []string{"1", "2"}, nil},
+		{overflowDropOldest, "1", []string{"2", "3"}, nil},
+		{overflowDisconnect, "3", []string{"1", "2"}, errBufferFull},
+	} {
+		p := stoppedPipeline(2, test.overflow)
+		o := p.newOutbox()
+		results := make(chan error, 3)
+		var err error
+		msgs := make(map[string]*Message)
+		for _, body := range []string{"1", "2", "3"} {
+			msgs[body] = resultMessage(body, results)
+			err = p.push(o, msgs[body])
+		}
+		assert.Equal(t, test.err, err, test.overflow)
+		assert.Equal(t, errBufferFull, <-results, "Dropped messages must fail")
+		assert.Equal(t, msgs[test.kept[0]], <-o.queue, test.overflow)
+		assert.Equal(t, msgs[test.kept[1]], <-o.queue, test.overflow)
+		assert.Len(t, o.lane.ready, 1, "Outboxes must be queued once")
+	}
+
+	p := stoppedPipeline(1, overflowBlock)
+	o := p.newOutbox()
+	assert.Nil(t, p.push(o, NewMessage("1")))
+	pushed := make(chan error)
+	go func() { pushed <- p.push(o, NewMessage("2")) }()
+	select {
+	case <-pushed:
+		t.Fatal("Push must block on a full buffer")
+	case <-time.After(50 * time.Millisecond):
+	}
+	<-o.queue
+	assert.Nil(t, <-pushed)
+}
+
+func TestPipeline_Workers(t *testing.T) {
+	p := newPipeline(&ackPublisher{}, &PipelineConfig{Workers: 2, Buffer: 4, Overflow: overflowBlock})
+	results := make(chan error, 400)
+	outboxes := make([]*outbox, 20)
+	for i := range outboxes {
+		outboxes[i] = p.newOutbox()
+	}
+	for i := 0; i < 400; i++ {
+		assert.Nil(t, p.push(outboxes[i%len(outboxes)], resultMessage(strconv.Itoa(i), results)))
+	}
+	for i := 0; i < 400; i++ {
+		select {
+		case err := <-results:
+			assert.Nil(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %d of the messages were published", i)
+		}
+	}
+	for _, o := range outboxes {
+		assert.Len(t, o.queue, 0)
+	}
+}
+
+// startPublisher counts its listeners and tells when they end
+type startPublisher struct {
+	ackPublisher
+	starts int32
+	ended  chan bool
+}
+
+func (p *startPublisher) Start(in <-chan *Message) {
+	atomic.AddInt32(&p.starts, 1)
+	p.ackPublisher.Start(in)
+	p.ended <- true
+}
+
+func TestPipeline_Close(t *testing.T) {
+	sp := &startPublisher{ended: make(chan bool, 4)}
+	p := newPipeline(sp, &PipelineConfig{Workers: 4, Buffer: 64, Overflow: overflowBlock})
+	results := make(chan error, 200)
+	outboxes := make([]*outbox, 8)
+	for i := range outboxes {
+		outboxes[i] = p.newOutbox()
+	}
+	for i := 0; i < 200; i++ {
+		assert.Nil(t, p.push(outboxes[i%len(outboxes)], resultMessage(strconv.Itoa(i), results)))
+	}
+
+	// the queued messages are handed on before the listener ends
+	p.close()
+	select {
+	case <-sp.ended:
+	case <-time.After(5 * time.Second):
+		t.Fatal("publisher listener did not end")
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&sp.starts), "The publisher must be started once")
+	assert.Len(t, results, 200, "Queued messages must be published on close")
+
+	m := resultMessage("late", make(chan error, 1))
+	assert.Equal(t, errPipelineClosed, p.push(outboxes[0], m), "Messages pushed once closed must fail")
+	p.close()
+}
+
+func TestPipeline_Order(t *testing.T) {
+	op := &orderPublisher{}
+	p := newPipeline(op, &PipelineConfig{Workers: 4, Buffer: 64, Overflow: overflowBlock})
+	results := make(chan error, 800)
+	outboxes := make([]*outbox, 8)
+	for i := range outboxes {
+		outboxes[i] = p.newOutbox()
+	}
+	for i := 0; i < 800; i++ {
+		o := i % len(outboxes)
+		assert.Nil(t, p.push(outboxes[o], resultMessage(fmt.Sprintf("%d-%d", o, i/len(outboxes)), results)))
+	}
+	for i := 0; i < 800; i++ {
+		select {
+		case <-results:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %d of the messages were published", i)
+		}
+	}
+
+	next := make([]int, len(outboxes))
+	for _, body := range op.bodies {
+		var o, n int
+		fmt.Sscanf(body, "%d-%d", &o, &n)
+		if !assert.Equal(t, next[o], n, "Messages of a connection must be published in order") {
+			return
+		}
+		next[o]++
+	}
+}
+
+// BenchmarkPipeline measures the throughput of 10k connections sending
+// to a backend taking the messages right away
+func BenchmarkPipeline(b *testing.B) {
+	p := newPipeline(&ackPublisher{}, &PipelineConfig{
+		Workers: defaultPipelineWorkers(), Buffer: defaultPipelineBuffer, Overflow: overflowBlock})
+	outboxes := make([]*outbox, 10000)
+	for i := range outboxes {
+		outboxes[i] = p.newOutbox()
+	}
+	var wg sync.WaitGroup
+	wg.Add(b.N)
+	msgs := make([]*Message, b.N)
+	for i := range msgs {
+		msgs[i] = NewMessage("benchmark")
+		msgs[i].delivered = func(error) { wg.Done() }
+	}
+	b.ResetTimer()
+
+	var senders sync.WaitGroup
+	senders.Add(len(outboxes))
+	for i := range outboxes {
+		go func(i int) {
+			defer senders.Done()
+			for j := i; j < b.N; j += len(outboxes) {
+				p.push(outboxes[i], msgs[j])
+			}
+		}(i)
+	}
+	senders.Wait()
+	wg.Wait()
+}
+
+// BenchmarkIdleConnections measures the memory and goroutines of 10k idle
+// connections, the client ends are in the same process and are counted
+// too, e.g. go test -run - -bench IdleConnections
+func BenchmarkIdleConnections(b *testing.B) {
+	defer func(p Publisher) { pub = p }(pub)
+	pub = &ackPublisher{}
+	ts, br := newTestServer(b)
+	defer ts.Close()
+
+	b.Run("10k", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			idleConnections(b, ts, br, 10000)
+		}
+	})
+}
+
+// idleConnections opens n connections and logs what each of them costs
+// once they are all connected
+func idleConnections(b *testing.B, ts *httptest.Server, br *broker, n int) {
+	b.StopTimer()
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	goroutines := runtime.NumGoroutine()
+	b.StartTimer()
+
+	conns := make([]*websocket.Conn, n)
+	for i := range conns {
+		conns[i] = dialTestServer(b, ts, "/")
+	}
+	for len(br.list()) < n {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	b.StopTimer()
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	inuse := func(m *runtime.MemStats) int64 { return int64(m.HeapInuse + m.StackInuse) }
+	b.Logf("%d connections: %d bytes and %.1f goroutines per connection", n,
+		(inuse(&after)-inuse(&before))/int64(n), float64(runtime.NumGoroutine()-goroutines)/float64(n))
+	for _, ws := range conns {
+		ws.Close()
+	}
+	for len(br.list()) > 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	b.StartTimer()
+}
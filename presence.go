@@ -0,0 +1,300 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// presence event types
+const (
+	presenceConnected    = "connected"
+	presenceDisconnected = "disconnected"
+	presenceAuthFailed   = "auth_failed"
+	presenceStarted      = "gateway_started"
+)
+
+// reasons a connection ended
+const (
+	reasonClientClosed = "client_closed"
+	reasonServerClosed = "server_closed"
+	reasonError        = "error"
+	reasonTimeout      = "timeout"
+	reasonIdle         = "idle_timeout"
+)
+
+const (
+	defaultPresenceBuffer = 1024
+	headerType            = "type"
+)
+
+var (
+	presenceEvents = newCounter("gateway_presence_events_total",
+		"Presence events by type and result.", "type", "result")
+
+	// presence publishes the presence events when enabled
+	presence *PresencePublisher
+)
+
+// PresenceEvent tells that a device came or went
+type PresenceEvent struct {
+	Type     string    `json:"type"`
+	Device   string    `json:"device,omitempty"`
+	Remote   string    `json:"remote,omitempty"`
+	Gateway  string    `json:"gateway"`
+	Conn     int64     `json:"conn,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+	Code     int       `json:"code,omitempty"`
+	Duration float64   `json:"duration,omitempty"`
+	On       time.Time `json:"on"`
+}
+
+// PresencePublisher produces the presence events to the presence topic,
+// events are dropped rather than stalling the connections when the
+// backend is not keeping up
+type PresencePublisher struct {
+	gateway  string
+	topic    string
+	in       chan *sarama.ProducerMessage
+	producer sarama.AsyncProducer
+	pub      Publisher
+	done     chan bool
+	mu       sync.RWMutex
+	closed   bool
+}
+
+// NewPresencePublisher connects to Kafka and starts producing the events,
+// without Kafka the events are published with the backend of the gateway
+func NewPresencePublisher(clientID string, args *PubConfig) *PresencePublisher {
+	if !usesBackend(args, "kafka") {
+		presenceArgs := *args
+		presenceArgs.Topic = args.Presence.Topic
+		presenceArgs.File.Name = args.Presence.Topic
+		pub := newPublisher(args.Backend)
+		pub.Config(clientID, &presenceArgs)
+		return newPresenceRelay(clientID, args.Presence.Topic, pub)
+	}
+	config, err := newKafkaConfig(clientID, args)
+	if err != nil {
+		logger.Fatalf("Invalid Kafka producer configuration: %v", err)
+	}
+	producer, err := sarama.NewAsyncProducer(args.URI, config)
+	if err != nil {
+		logger.Fatalf("Failed to start Kafka presence producer: %v", err)
+	}
+	return newPresencePublisher(clientID, args.Presence.Topic, producer)
+}
+
+func newPresencePublisher(clientID, topic string, producer sarama.AsyncProducer) *PresencePublisher {
+	p := &PresencePublisher{
+		gateway:  clientID,
+		topic:    topic,
+		in:       make(chan *sarama.ProducerMessage, defaultPresenceBuffer),
+		producer: producer,
+		done:     make(chan bool),
+	}
+	go p.forward()
+	go p.dispatch()
+	return p
+}
+
+func newPresenceRelay(clientID, topic string, pub Publisher) *PresencePublisher {
+	p := &PresencePublisher{
+		gateway: clientID,
+		topic:   topic,
+		in:      make(chan *sarama.ProducerMessage, defaultPresenceBuffer),
+		pub:     pub,
+		done:    make(chan bool),
+	}
+	go p.relay()
+	return p
+}
+
+// started publishes the start of the gateway, the connections of its
+// previous run are gone
+func (p *PresencePublisher) started() {
+	p.send(&PresenceEvent{Type: presenceStarted, On: time.Now().UTC()})
+}
+
+// connected publishes the connection of the client
+func (p *PresencePublisher) connected(c *handler) {
+	p.send(&PresenceEvent{Type: presenceConnected, Device: c.device,
+		Remote: c.remote, Conn: c.id, On: c.since})
+}
+
+// disconnected publishes the end of the connection, err is the error
+// the client stopped being read with
+func (p *PresencePublisher) disconnected(c *handler, err error) {
+	e := &PresenceEvent{Type: presenceDisconnected, Device: c.device,
+		Remote: c.remote, Conn: c.id, On: time.Now().UTC()}
+	e.Duration = e.On.Sub(c.since).Seconds()
+	e.Reason, e.Code = c.disconnectReason(err)
+	p.send(e)
+}
+
+// authFailed publishes the rejection of the request with the reason
+func (p *PresencePublisher) authFailed(req *http.Request, device, reason string) {
+	p.send(&PresenceEvent{Type: presenceAuthFailed, Device: device,
+		Remote: req.RemoteAddr, Reason: reason, On: time.Now().UTC()})
+}
+
+func (p *PresencePublisher) send(e *PresenceEvent) {
+	if p == nil {
+		return
+	}
+	e.Gateway = p.gateway
+	b, err := json.Marshal(e)
+	if err != nil {
+		logger.Errorf("unable to marshal: %v", err)
+		return
+	}
+	m := &sarama.ProducerMessage{
+		Topic:   p.topic,
+		Value:   sarama.ByteEncoder(b),
+		Headers: []sarama.RecordHeader{{Key: []byte(headerType), Value: []byte(e.Type)}},
+	}
+	if len(e.Device) > 0 {
+		m.Key = sarama.StringEncoder(e.Device)
+	}
+	// clients still disconnect while the gateway shuts down
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		presenceEvents.with(e.Type, "dropped").inc()
+		return
+	}
+	select {
+	case p.in <- m:
+	default:
+		presenceEvents.with(e.Type, "dropped").inc()
+	}
+}
+
+// forward produces the events to the topic
+func (p *PresencePublisher) forward() {
+	for m := range p.in {
+		p.producer.Input() <- m
+	}
+	p.producer.AsyncClose()
+}
+
+// relay hands the events to the publisher of the backend and counts
+// their results
+func (p *PresencePublisher) relay() {
+	defer close(p.done)
+	msgs := make(chan *Message)
+	stopped := make(chan bool)
+	go func() {
+		p.pub.Start(msgs)
+		close(stopped)
+	}()
+	for m := range p.in {
+		b, _ := m.Value.Encode()
+		msg := NewMessage(string(b))
+		if m.Key != nil {
+			k, _ := m.Key.Encode()
+			msg.Device = string(k)
+		}
+		typ := eventType(m)
+		msg.delivered = func(err error) {
+			if err != nil {
+				presenceEvents.with(typ, "failed").inc()
+				logger.Errorf("Error on presence send for [%s]: %v", p.topic, err)
+				return
+			}
+			presenceEvents.with(typ, "published").inc()
+		}
+		msgs <- msg
+	}
+	close(msgs)
+	<-stopped
+	if err := closePublisher(p.pub); err != nil {
+		logger.Errorf("Error on presence publisher close: %v", err)
+	}
+}
+
+// dispatch counts the results of the producer
+func (p *PresencePublisher) dispatch() {
+	defer close(p.done)
+	successes, errs := p.producer.Successes(), p.producer.Errors()
+	for successes != nil || errs != nil {
+		select {
+		case m, ok := <-successes:
+			if !ok {
+				successes = nil
+				continue
+			}
+			presenceEvents.with(eventType(m), "published").inc()
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			presenceEvents.with(eventType(err.Msg), "failed").inc()
+			logger.Errorf("Error on presence send for [%s]: %v", err.Msg.Topic, err.Err)
+		}
+	}
+}
+
+// Close stops the producer once the pending events are sent
+func (p *PresencePublisher) Close() error {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.in)
+	p.mu.Unlock()
+	<-p.done
+	return nil
+}
+
+func eventType(m *sarama.ProducerMessage) string {
+	for _, h := range m.Headers {
+		if string(h.Key) == headerType {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// disconnectReason tells why the connection ended and the close code
+// it was closed with by the gateway
+func (c *handler) disconnectReason(err error) (string, int) {
+	if code := atomic.LoadInt32(&c.closeCode); code != 0 {
+		return reasonServerClosed, int(code)
+	}
+	switch err {
+	case io.EOF:
+		return reasonClientClosed, 0
+	case errReadTimeout:
+		return reasonTimeout, 0
+	case errIdleTimeout:
+		return reasonIdle, 0
+	}
+	return reasonError, 0
+}
@@ -0,0 +1,156 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"code.google.com/p/go.net/websocket"
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestPresence replaces the presence publisher with one producing
+// to a mock, the events are read from the returned channel
+func newTestPresence(t *testing.T, n int) (*PresencePublisher, <-chan *PresenceEvent) {
+	config := sarama.NewConfig()
+	config.Producer.Return.Successes = true
+	producer := mocks.NewAsyncProducer(t, config)
+	events := make(chan *PresenceEvent, n)
+	for i := 0; i < n; i++ {
+		producer.ExpectInputWithCheckerFunctionAndSucceed(func(val []byte) error {
+			var e PresenceEvent
+			err := json.Unmarshal(val, &e)
+			events <- &e
+			return err
+		})
+	}
+	return newPresencePublisher("g1", "presence", producer), events
+}
+
+func nextEvent(t *testing.T, events <-chan *PresenceEvent) *PresenceEvent {
+	t.Helper()
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("presence event was not published")
+	}
+	return nil
+}
+
+func TestPresence_Connection(t *testing.T) {
+	pub = &ackPublisher{}
+	p, events := newTestPresence(t, 4)
+	presence = p
+	defer func() { presence = nil }()
+	b := newBroker()
+	b.authVal = deviceAuth{}
+	ts, _ := serveTestBroker(t, b)
+	defer ts.Close()
+
+	ws := dialTestServer(t, ts, "/?device=dev1")
+	e := nextEvent(t, events)
+	assert.Equal(t, presenceConnected, e.Type)
+	assert.Equal(t, "dev1", e.Device)
+	assert.Equal(t, "g1", e.Gateway)
+	assert.NotEmpty(t, e.Remote)
+	ws.Close()
+
+	e = nextEvent(t, events)
+	assert.Equal(t, presenceDisconnected, e.Type)
+	assert.Equal(t, reasonClientClosed, e.Reason)
+	assert.Equal(t, 0, e.Code)
+	assert.True(t, e.Duration > 0, "Disconnects must carry the session duration")
+
+	ws = dialTestServer(t, ts, "/?device=dev2")
+	defer ws.Close()
+	nextEvent(t, events)
+	waitClients(t, b, 1)[0].close(closeGoingAway)
+	var m string
+	ws.SetDeadline(time.Now().Add(5 * time.Second))
+	assert.Equal(t, io.EOF, websocket.Message.Receive(ws, &m))
+	ws.Close()
+	e = nextEvent(t, events)
+	assert.Equal(t, reasonServerClosed, e.Reason)
+	assert.Equal(t, closeGoingAway, e.Code, "Disconnects by the gateway must carry the close code")
+
+	assert.Nil(t, p.Close())
+	p.connected(&handler{device: "late"})
+}
+
+func TestPresence_AuthFailed(t *testing.T) {
+	p, events := newTestPresence(t, 1)
+	req, _ := http.NewRequest("GET", "http://localhost/ws", nil)
+	req.RemoteAddr = "10.0.0.7:5000"
+	p.authFailed(req, "dev1", "invalid_credentials")
+
+	e := nextEvent(t, events)
+	assert.Equal(t, &PresenceEvent{Type: presenceAuthFailed, Device: "dev1", Remote: "10.0.0.7:5000",
+		Gateway: "g1", Reason: "invalid_credentials", On: e.On}, e)
+	assert.Nil(t, p.Close())
+}
+
+func TestPresence_Backend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gateway-presence")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := NewPresencePublisher("g1", &PubConfig{Backend: "file", Topic: "messages",
+		File: FileConfig{Dir: dir, Fsync: "always"}, Presence: PresenceConfig{Enabled: true, Topic: "presence"}})
+	p.started()
+	p.connected(&handler{device: "dev1", id: 3})
+	assert.Nil(t, p.Close())
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "presence"+fileExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if assert.Len(t, lines, 2, "Events must be written to the presence file") {
+		var m Message
+		var e PresenceEvent
+		assert.Nil(t, json.Unmarshal([]byte(lines[1]), &m))
+		assert.Nil(t, json.Unmarshal([]byte(m.Body), &e))
+		assert.Equal(t, presenceConnected, e.Type)
+		assert.Equal(t, "dev1", e.Device)
+		assert.Equal(t, "g1", e.Gateway)
+	}
+}
+
+func TestHandler_DisconnectReason(t *testing.T) {
+	c := &handler{}
+	reason, code := c.disconnectReason(io.EOF)
+	assert.Equal(t, reasonClientClosed, reason)
+	assert.Equal(t, 0, code)
+	reason, _ = c.disconnectReason(errors.New("reset"))
+	assert.Equal(t, reasonError, reason)
+	c.closeCode = closePolicyViolation
+	reason, code = c.disconnectReason(io.EOF)
+	assert.Equal(t, reasonServerClosed, reason)
+	assert.Equal(t, closePolicyViolation, code)
+}
@@ -16,68 +16,68 @@
 package main
 
 import (
-	"github.com/Shopify/sarama"
+	"io"
 	"log"
-	"time"
 )
 
 var (
-	qProducer sarama.AsyncProducer
+	pub Publisher
 )
 
-func queueInit() {
-
-	config := sarama.NewConfig()
-
-	config.ClientID = args.ID
+// Publisher defines the implementation for publisher
+type Publisher interface {
+	Config(clientID string, args *PubConfig)
+	Start(in <-chan *Message)
+}
 
-	// Acks
-	if args.Pub.Ack {
-		config.Producer.RequiredAcks = sarama.WaitForAll
-	} else {
-		config.Producer.RequiredAcks = sarama.WaitForLocal
+// newPublisher factors the publisher for the named backend
+func newPublisher(backend string) Publisher {
+	switch backend {
+	case "kafka":
+		return NewKafkaPublisher()
+	case "file":
+		return NewFilePublisher()
+	case "redis":
+		return NewRedisPublisher()
+	case "fanout":
+		return NewFanoutPublisher()
 	}
+	log.Panicf("Invalid gateway publisher backend: %v", backend)
+	return nil
+}
 
-	// Compress
-	if args.Pub.Compress {
-		config.Producer.Compression = sarama.CompressionSnappy
-	} else {
-		config.Producer.Compression = sarama.CompressionNone
+// closePublisher flushes and releases the publisher if it holds resources
+func closePublisher(p Publisher) error {
+	if c, ok := p.(io.Closer); ok {
+		return c.Close()
 	}
+	return nil
+}
 
-	// Flush Intervals
-	if args.Pub.FlushFreq > 0 {
-		config.Producer.Flush.Frequency = time.Duration(args.Pub.FlushFreq) * time.Second
-	} else {
-		config.Producer.Flush.Frequency = 1 * time.Second
+func queueInit() {
+	if args.Pub.DeadLetter.Enabled {
+		logger.Infof("Using dead-letter queue %s", args.Pub.DeadLetter.Topic)
+		deadLetters = NewDeadLetterQueue()
+		deadLetters.Config(args.ID, &args.Pub)
 	}
-
-	producer, err := sarama.NewAsyncProducer(args.Pub.URI, config)
-	if err != nil {
-		log.Fatalln("Failed to start Kafka producer:", err)
+	if args.Pub.Presence.Enabled {
+		logger.Infof("Publishing presence events to %s", args.Pub.Presence.Topic)
+		presence = NewPresencePublisher(args.ID, &args.Pub)
+		presence.started()
 	}
-
-	qProducer = producer
-
-}
-
-// Start fires a publisher listener
-func produce(in <-chan *Message) {
-
-	for {
-		msg := <-in
-		select {
-		case qProducer.Input() <- &sarama.ProducerMessage{
-			Topic: args.Pub.Topic,
-			Key:   nil,
-			Value: sarama.StringEncoder(msg.ToBytes()),
-		}:
-			if args.Trace {
-				log.Printf("Queue[%s] < %s", args.Pub.Topic, msg)
-			}
-		case err := <-qProducer.Errors():
-			log.Printf("Error on queue send for [%s]: %v", args.Pub.Topic, err.Err)
-		}
+	if args.Pub.Will.Enabled {
+		logger.Infof("Publishing last wills to %s", args.Pub.Will.Topic)
+		startWills(args.ID, &args.Pub)
 	}
-
+	logger.Infof("Using %s publisher", args.Pub.Backend)
+	pub = newPublisher(args.Pub.Backend)
+	if args.Pub.Spool.Enabled {
+		logger.Infof("Spooling to %s while the publisher is unavailable", args.Pub.Spool.Dir)
+		pub = NewSpoolPublisher(pub)
+	}
+	if args.Pub.Breaker.Enabled {
+		logger.Infof("Breaking the circuit after %d errors", args.Pub.Breaker.Errors)
+		pub = NewBreakerPublisher(pub)
+	}
+	pub.Config(args.ID, &args.Pub)
 }
@@ -0,0 +1,485 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Actions taken on the messages over a rate limit or a quota
+const (
+	rateDrop       = "drop"
+	rateDelay      = "delay"
+	rateThrottle   = "throttle"
+	rateDisconnect = "disconnect"
+)
+
+const (
+	// quotas are either kept per device or per tenant
+	quotaByDevice = "device"
+	quotaByTenant = "tenant"
+
+	// quotaDay is the format of the day the usage is counted for
+	quotaDay = "2006-01-02"
+
+	// limits a message can be over
+	limitConnectionRate = "connection"
+	limitDeviceRate     = "device"
+	limitDailyQuota     = "quota"
+
+	defaultTenantClaim = "tenant"
+)
+
+var (
+	errRateLimited   = errors.New("rate limit exceeded")
+	errQuotaExceeded = errors.New("daily quota exceeded")
+
+	rateLimited = newCounter("gateway_rate_limited_total",
+		"Messages over a rate limit or the daily quota by limit and action.", "limit", "action")
+	usageMessages = newCounter("gateway_usage_messages_total",
+		"Messages accepted within the quotas of the devices or tenants.", "by")
+	usageBytes = newCounter("gateway_usage_bytes_total",
+		"Message bytes accepted within the quotas of the devices or tenants.", "by")
+
+	// rates limits the clients when enabled
+	rates *RateLimits
+
+	// usageSaveEvery is how often the usage is written to the usage file
+	usageSaveEvery = time.Minute
+)
+
+// tokenBucket holds up to one second worth of tokens, a rate of 0 is unlimited
+type tokenBucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate int, now time.Time) tokenBucket {
+	return tokenBucket{rate: float64(rate), tokens: float64(rate), last: now}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if now.After(b.last) {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.rate {
+			b.tokens = b.rate
+		}
+		b.last = now
+	}
+}
+
+// wait returns how long it takes until n tokens are available, n larger
+// than the bucket only waits for a full bucket
+func (b *tokenBucket) wait(n float64) time.Duration {
+	if b.rate <= 0 {
+		return 0
+	}
+	if n > b.rate {
+		n = b.rate
+	}
+	if b.tokens >= n {
+		return 0
+	}
+	return time.Duration((n - b.tokens) / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) take(n float64) {
+	if b.rate > 0 {
+		b.tokens -= n
+	}
+}
+
+// rateLimit limits the messages and the bytes per second of either a
+// connection or all the connections of a device
+type rateLimit struct {
+	sync.Mutex
+	messages tokenBucket
+	bytes    tokenBucket
+}
+
+// newRateLimit returns nil when neither the messages nor the bytes are limited
+func newRateLimit(messages, kb int, now time.Time) *rateLimit {
+	if messages <= 0 && kb <= 0 {
+		return nil
+	}
+	return &rateLimit{
+		messages: newTokenBucket(messages, now),
+		bytes:    newTokenBucket(kb*1024, now),
+	}
+}
+
+// wait returns how long the message of size bytes has to wait for
+func (r *rateLimit) wait(size int, now time.Time) time.Duration {
+	if r == nil {
+		return 0
+	}
+	r.Lock()
+	defer r.Unlock()
+	r.messages.refill(now)
+	r.bytes.refill(now)
+	wait := r.messages.wait(1)
+	if w := r.bytes.wait(float64(size)); w > wait {
+		wait = w
+	}
+	return wait
+}
+
+// take counts the message of size bytes
+func (r *rateLimit) take(size int) {
+	if r == nil {
+		return
+	}
+	r.Lock()
+	defer r.Unlock()
+	r.messages.take(1)
+	r.bytes.take(float64(size))
+}
+
+// Usage is what a device or tenant sent, today and since the gateway started
+// or, with a usage file, since the file was started
+type Usage struct {
+	Key           string `json:"key"`
+	Day           string `json:"day"`
+	Messages      int64  `json:"messages"`
+	Bytes         int64  `json:"bytes"`
+	Rejected      int64  `json:"rejected"`
+	TotalMessages int64  `json:"total_messages"`
+	TotalBytes    int64  `json:"total_bytes"`
+}
+
+// quota counts the usage of a device or tenant against the daily limits
+type quota struct {
+	sync.Mutex
+	usage       Usage
+	maxMessages int64
+	maxBytes    int64
+
+	// conns is guarded by the RateLimits holding the quota
+	conns int
+}
+
+// take counts the message of size bytes unless it is over the daily quota
+func (q *quota) take(size int, now time.Time) bool {
+	if q == nil {
+		return true
+	}
+	q.Lock()
+	defer q.Unlock()
+	if day := now.UTC().Format(quotaDay); day != q.usage.Day {
+		q.usage.Day, q.usage.Messages, q.usage.Bytes, q.usage.Rejected = day, 0, 0, 0
+	}
+	if (q.maxMessages > 0 && q.usage.Messages >= q.maxMessages) ||
+		(q.maxBytes > 0 && q.usage.Bytes+int64(size) > q.maxBytes) {
+		q.usage.Rejected++
+		return false
+	}
+	q.usage.Messages++
+	q.usage.Bytes += int64(size)
+	q.usage.TotalMessages++
+	q.usage.TotalBytes += int64(size)
+	return true
+}
+
+func (q *quota) get() Usage {
+	q.Lock()
+	defer q.Unlock()
+	return q.usage
+}
+
+// untilReset returns how long until the daily quotas are reset at midnight UTC
+func untilReset(now time.Time) time.Duration {
+	y, m, d := now.UTC().Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC).Sub(now)
+}
+
+// deviceRate is the rate limit shared by the connections of a device
+type deviceRate struct {
+	limit *rateLimit
+	conns int
+}
+
+// RateLimits keeps the rate limits of the devices and the quotas of the
+// devices or tenants, the quotas are counted by each gateway on its own
+type RateLimits struct {
+	sync.Mutex
+	conf    *RateLimitConfig
+	devices map[string]*deviceRate
+	quotas  map[string]*quota
+
+	// day is when the quotas were last expired
+	day string
+
+	closed bool
+	done   chan bool
+}
+
+// newRateLimits creates the limits of the configuration, the usage left
+// in the usage file by the previous run is counted on
+func newRateLimits(conf *RateLimitConfig) *RateLimits {
+	r := &RateLimits{
+		conf:    conf,
+		devices: make(map[string]*deviceRate),
+		quotas:  make(map[string]*quota),
+		done:    make(chan bool),
+	}
+	if len(conf.UsageFile) > 0 {
+		if err := r.load(); err != nil {
+			logger.Errorf("Error on usage load from %s: %v", conf.UsageFile, err)
+		}
+	}
+	return r
+}
+
+// newQuota returns the quota of the usage
+func (r *RateLimits) newQuota(usage Usage) *quota {
+	return &quota{
+		usage:       usage,
+		maxMessages: r.conf.DailyMessages,
+		maxBytes:    r.conf.DailyMB * 1024 * 1024,
+	}
+}
+
+// load restores the usage of the usage file
+func (r *RateLimits) load() error {
+	b, err := ioutil.ReadFile(r.conf.UsageFile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var usage []Usage
+	if err := json.Unmarshal(b, &usage); err != nil {
+		return err
+	}
+	for _, u := range usage {
+		r.quotas[u.Key] = r.newQuota(u)
+	}
+	return nil
+}
+
+// save writes the usage to the usage file, replacing it at once
+func (r *RateLimits) save() error {
+	b, err := json.Marshal(r.usage())
+	if err != nil {
+		return err
+	}
+	tmp := r.conf.UsageFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.conf.UsageFile)
+}
+
+// persist saves the usage to the usage file regularly until closed
+func (r *RateLimits) persist() {
+	ticker := time.NewTicker(usageSaveEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.save(); err != nil {
+				logger.Errorf("Error on usage save to %s: %v", r.conf.UsageFile, err)
+			}
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// Close stops saving the usage and saves it one last time
+func (r *RateLimits) Close() error {
+	if r == nil || len(r.conf.UsageFile) == 0 {
+		return nil
+	}
+	r.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.done)
+	}
+	r.Unlock()
+	return r.save()
+}
+
+// attach returns the limits applying to a new connection
+func (r *RateLimits) attach(c *handler) (conn, device *rateLimit, q *quota) {
+	if r == nil {
+		return nil, nil, nil
+	}
+	now := time.Now()
+	conn = newRateLimit(r.conf.Messages, r.conf.KB, now)
+
+	r.Lock()
+	defer r.Unlock()
+	r.expire(now)
+	if len(c.device) > 0 {
+		d, ok := r.devices[c.device]
+		if !ok {
+			d = &deviceRate{limit: newRateLimit(r.conf.DeviceMessages, r.conf.DeviceKB, now)}
+			r.devices[c.device] = d
+		}
+		d.conns++
+		device = d.limit
+	}
+	if key := c.quotaKey(r.conf.QuotaBy); len(key) > 0 {
+		q, ok := r.quotas[key]
+		if !ok {
+			q = r.newQuota(Usage{Key: key})
+			r.quotas[key] = q
+		}
+		q.conns++
+		return conn, device, q
+	}
+	return conn, device, nil
+}
+
+// detach releases the rate limit of the device once its last connection ended,
+// the quotas are kept until the day they were used on is over
+func (r *RateLimits) detach(c *handler) {
+	if r == nil {
+		return
+	}
+	r.Lock()
+	defer r.Unlock()
+	if d, ok := r.devices[c.device]; ok {
+		if d.conns--; d.conns <= 0 {
+			delete(r.devices, c.device)
+		}
+	}
+	if q, ok := r.quotas[c.quotaKey(r.conf.QuotaBy)]; ok {
+		q.conns--
+	}
+	r.expire(time.Now())
+}
+
+// expire drops the quotas of the devices or tenants without connections
+// once the day they were used on is over, it runs once a day
+func (r *RateLimits) expire(now time.Time) {
+	day := now.UTC().Format(quotaDay)
+	if day == r.day {
+		return
+	}
+	r.day = day
+	for key, q := range r.quotas {
+		if q.conns <= 0 && q.get().Day != day {
+			delete(r.quotas, key)
+		}
+	}
+}
+
+// usage returns the usage of the devices or tenants sorted by key
+func (r *RateLimits) usage() []Usage {
+	usage := []Usage{}
+	if r == nil {
+		return usage
+	}
+	r.Lock()
+	r.expire(time.Now())
+	quotas := make([]*quota, 0, len(r.quotas))
+	for _, q := range r.quotas {
+		quotas = append(quotas, q)
+	}
+	r.Unlock()
+	for _, q := range quotas {
+		usage = append(usage, q.get())
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Key < usage[j].Key })
+	return usage
+}
+
+// quotaKey returns the device or the tenant the usage of the connection is
+// counted for, tenants fall back to the device
+func (c *handler) quotaKey(by string) string {
+	if by == quotaByTenant && len(c.tenant) > 0 {
+		return c.tenant
+	}
+	return c.device
+}
+
+// admit applies the rate limits and the daily quota to the frame, it tells
+// whether the frame is to be published
+func (c *handler) admit(frame string) bool {
+	if c.rates == nil {
+		return true
+	}
+	if retry, err := c.limit(len(frame), c.rates.conf.Action); err != nil {
+		c.reject(frame, err, retry)
+		return false
+	}
+	return true
+}
+
+// limit counts a message of size bytes against the rate limits and the
+// daily quota, a message over them fails along with how long until it
+// would be within them, unless the action is to delay it
+func (c *handler) limit(size int, action string) (time.Duration, error) {
+	if c.rates == nil {
+		return 0, nil
+	}
+	now := time.Now()
+	limit, wait := limitConnectionRate, c.rate.wait(size, now)
+	if w := c.deviceRate.wait(size, now); w > wait {
+		limit, wait = limitDeviceRate, w
+	}
+	if wait > 0 {
+		rateLimited.with(limit, action).inc()
+		if action != rateDelay {
+			return wait, errRateLimited
+		}
+		// backpressure, the client is not read from meanwhile
+		time.Sleep(wait)
+		now = time.Now()
+	}
+	c.rate.take(size)
+	c.deviceRate.take(size)
+
+	if !c.quota.take(size, now) {
+		rateLimited.with(limitDailyQuota, action).inc()
+		return untilReset(now), errQuotaExceeded
+	}
+	if c.quota != nil {
+		usageMessages.with(c.rates.conf.QuotaBy).inc()
+		usageBytes.with(c.rates.conf.QuotaBy).add(float64(size))
+	}
+	return 0, nil
+}
+
+// reject nacks the frame and acts on the client as configured, delaying
+// a frame over the daily quota drops it
+func (c *handler) reject(frame string, err error, retry time.Duration) {
+	c.log.Debugf("message rejected: %v", err)
+	if c.acks {
+		ref, _, _ := parseAckFrame(frame)
+		c.acknowledge(ref)(err)
+	}
+	switch c.rates.conf.Action {
+	case rateThrottle:
+		c.throttle(retry)
+	case rateDisconnect:
+		if atomic.LoadInt32(&c.closing) == 1 {
+			return
+		}
+		c.log.Warnf("disconnecting: %v", err)
+		c.disconnect(closePolicyViolation, disconnectGrace)
+	}
+}
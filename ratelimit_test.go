@@ -0,0 +1,212 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"code.google.com/p/go.net/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(2, now)
+	assert.Equal(t, time.Duration(0), b.wait(1))
+	b.take(1)
+	b.take(1)
+	assert.Equal(t, 500*time.Millisecond, b.wait(1))
+	b.refill(now.Add(250 * time.Millisecond))
+	assert.Equal(t, 250*time.Millisecond, b.wait(1))
+	b.refill(now.Add(time.Hour))
+	assert.Equal(t, float64(2), b.tokens, "Buckets must hold one second worth of tokens")
+	assert.Equal(t, time.Duration(0), b.wait(10), "Large takes must only wait for a full bucket")
+
+	unlimited := newTokenBucket(0, now)
+	unlimited.take(100)
+	assert.Equal(t, time.Duration(0), unlimited.wait(100))
+}
+
+func TestRateLimit(t *testing.T) {
+	now := time.Now()
+	assert.Nil(t, newRateLimit(0, 0, now), "Unlimited connections must not get a limit")
+	var none *rateLimit
+	assert.Equal(t, time.Duration(0), none.wait(100, now))
+	none.take(100)
+
+	r := newRateLimit(0, 1, now)
+	assert.Equal(t, time.Duration(0), r.wait(1024, now))
+	r.take(1024)
+	assert.Equal(t, 500*time.Millisecond, r.wait(512, now), "Bytes must be limited on their own")
+}
+
+func TestQuota(t *testing.T) {
+	day := time.Date(2026, 10, 19, 23, 0, 0, 0, time.UTC)
+	q := &quota{usage: Usage{Key: "dev1"}, maxMessages: 2, maxBytes: 100}
+	assert.True(t, q.take(10, day))
+	assert.False(t, q.take(95, day), "Messages must be rejected over the byte quota")
+	assert.True(t, q.take(10, day))
+	assert.False(t, q.take(1, day), "Messages must be rejected over the message quota")
+	assert.Equal(t, Usage{Key: "dev1", Day: "2026-10-19", Messages: 2, Bytes: 20, Rejected: 2,
+		TotalMessages: 2, TotalBytes: 20}, q.get())
+
+	assert.True(t, q.take(5, day.Add(2*time.Hour)), "Quotas must be reset daily")
+	assert.Equal(t, Usage{Key: "dev1", Day: "2026-10-20", Messages: 1, Bytes: 5,
+		TotalMessages: 3, TotalBytes: 25}, q.get())
+	assert.Equal(t, time.Hour, untilReset(day))
+
+	var none *quota
+	assert.True(t, none.take(1000, day))
+}
+
+func TestRateLimits_Attach(t *testing.T) {
+	var none *RateLimits
+	conn, device, q := none.attach(&handler{device: "dev1"})
+	assert.Nil(t, conn)
+	assert.Nil(t, device)
+	assert.Nil(t, q)
+	none.detach(&handler{device: "dev1"})
+	assert.Empty(t, none.usage())
+
+	conf := &RateLimitConfig{Messages: 1, DeviceMessages: 2, QuotaBy: quotaByTenant}
+	rates = newRateLimits(conf)
+	defer func() { rates = nil }()
+	c1 := &handler{device: "dev1", tenant: "acme"}
+	c2 := &handler{device: "dev1"}
+	c1.rate, c1.deviceRate, c1.quota = rates.attach(c1)
+	c2.rate, c2.deviceRate, c2.quota = rates.attach(c2)
+	assert.NotEqual(t, c1.rate, c2.rate, "Connections must have their own limit")
+	assert.Equal(t, c1.deviceRate, c2.deviceRate, "Connections of a device must share its limit")
+	assert.Equal(t, "acme", c1.quota.usage.Key)
+	assert.Equal(t, "dev1", c2.quota.usage.Key, "Devices without a tenant must be counted on their own")
+
+	rates.detach(c1)
+	assert.Len(t, rates.devices, 1)
+	rates.detach(c2)
+	assert.Empty(t, rates.devices, "Device limits must be released with their last connection")
+	assert.Len(t, rates.usage(), 2, "Quotas must outlive the connections")
+
+	c3 := &handler{device: "dev2"}
+	c3.rate, c3.deviceRate, c3.quota = rates.attach(c3)
+	rates.Lock()
+	for _, q := range rates.quotas {
+		q.usage.Day = "2026-10-18"
+	}
+	rates.day = ""
+	rates.Unlock()
+	usage := rates.usage()
+	if assert.Len(t, usage, 1, "Quotas of a previous day must be dropped once unused") {
+		assert.Equal(t, "dev2", usage[0].Key)
+	}
+	rates.detach(c3)
+	assert.Len(t, rates.usage(), 1, "Quotas must be kept until their day is over")
+}
+
+func TestRateLimits_UsageFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gateway-usage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	conf := &RateLimitConfig{DailyMessages: 2, QuotaBy: quotaByDevice, UsageFile: filepath.Join(dir, "usage.json")}
+
+	r := newRateLimits(conf)
+	c := &handler{device: "dev1"}
+	_, _, c.quota = r.attach(c)
+	now := time.Now()
+	assert.True(t, c.quota.take(10, now))
+	r.detach(c)
+	assert.Nil(t, r.Close())
+
+	// the quota carries on after a restart
+	r = newRateLimits(conf)
+	_, _, c.quota = r.attach(c)
+	assert.True(t, c.quota.take(10, now))
+	assert.False(t, c.quota.take(10, now), "Quotas must be counted on after a restart")
+	if usage := r.usage(); assert.Len(t, usage, 1) {
+		assert.Equal(t, int64(2), usage[0].TotalMessages)
+		assert.Equal(t, int64(20), usage[0].TotalBytes)
+	}
+	r.detach(c)
+	assert.Nil(t, r.Close())
+}
+
+func TestHandler_RateLimit(t *testing.T) {
+	pub = &ackPublisher{}
+	rates = newRateLimits(&RateLimitConfig{DeviceMessages: 2, Action: rateThrottle, QuotaBy: quotaByDevice})
+	defer func() { rates = nil }()
+	b := newBroker()
+	b.authVal = deviceAuth{}
+	ts, _ := serveTestBroker(t, b)
+	defer ts.Close()
+
+	ws := dialTestServer(t, ts, "/?device=dev1&ack=true")
+	defer ws.Close()
+	ws.SetDeadline(time.Now().Add(5 * time.Second))
+	for _, id := range []string{"1", "2", "3"} {
+		assert.Nil(t, websocket.Message.Send(ws, `{"id": "`+id+`", "body": "m"}`))
+	}
+
+	replies := make(map[string]string)
+	for i := 0; i < 4; i++ {
+		var reply map[string]interface{}
+		assert.Nil(t, websocket.JSON.Receive(ws, &reply))
+		if reply["type"] == "throttle" {
+			assert.Equal(t, float64(1), reply["retry_after"])
+			continue
+		}
+		replies[reply["id"].(string)] = reply["type"].(string)
+		if reply["type"] == "nack" {
+			assert.Equal(t, errRateLimited.Error(), reply["reason"])
+		}
+	}
+	assert.Equal(t, map[string]string{"1": "ack", "2": "ack", "3": "nack"}, replies,
+		"Messages over the device limit must be rejected")
+
+	h := newAdminHandler(b, "secret")
+	var usage []Usage
+	assert.Nil(t, json.Unmarshal(adminRequest(h, "GET", "/admin/usage?key=dev1", "secret").Body.Bytes(), &usage))
+	if assert.Len(t, usage, 1) {
+		assert.Equal(t, int64(2), usage[0].Messages, "Only accepted messages must be counted")
+	}
+	assert.Equal(t, http.StatusOK, adminRequest(h, "GET", "/admin/usage", "secret").Code)
+}
+
+func TestHandler_RateLimitDisconnect(t *testing.T) {
+	pub = &ackPublisher{}
+	rates = newRateLimits(&RateLimitConfig{Messages: 1, Action: rateDisconnect, QuotaBy: quotaByDevice})
+	defer func() { rates = nil }()
+	b := newBroker()
+	ts, _ := serveTestBroker(t, b)
+	defer ts.Close()
+
+	ws := dialTestServer(t, ts, "/")
+	defer ws.Close()
+	ws.SetDeadline(time.Now().Add(5 * time.Second))
+	assert.Nil(t, websocket.Message.Send(ws, "one"))
+	assert.Nil(t, websocket.Message.Send(ws, "two"))
+	var m string
+	assert.Equal(t, io.EOF, websocket.Message.Receive(ws, &m), "Clients over the limit must be disconnected")
+	ws.Close()
+	waitClients(t, b, 0)
+}
@@ -0,0 +1,285 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	redisTopicPlaceholder = "{topic}"
+	defaultRedisPipeline  = 100
+	redisDialTimeout      = 10 * time.Second
+	redisMaxBackoff       = 30 * time.Second
+)
+
+// redisError represents an error reply sent back by the Redis server
+type redisError string
+
+func (e redisError) Error() string { return string(e) }
+
+// RedisPublisher is the type representing Redis Streams publisher imp,
+// it XADDs each envelope to a stream named after the topic
+type RedisPublisher struct {
+	conf   RedisConfig
+	stream string
+	queue  chan *Message
+	conn   net.Conn
+	r      *bufio.Reader
+	w      *bufio.Writer
+
+	connected int32
+}
+
+// NewRedisPublisher creates a new Redis Streams publisher object
+func NewRedisPublisher() Publisher {
+	return &RedisPublisher{}
+}
+
+// Config connects the publisher to the configured Redis server
+func (p *RedisPublisher) Config(clientID string, args *PubConfig) {
+	p.conf = args.Redis
+	if len(p.conf.Address) == 0 {
+		p.conf.Address = "127.0.0.1:6379"
+	}
+	if len(p.conf.Stream) == 0 {
+		p.conf.Stream = redisTopicPlaceholder
+	}
+	if p.conf.Pipeline <= 0 {
+		p.conf.Pipeline = defaultRedisPipeline
+	}
+	p.stream = strings.Replace(p.conf.Stream, redisTopicPlaceholder, args.Topic, -1)
+
+	if err := p.connect(); err != nil {
+		logger.Fatalf("Failed to start Redis publisher: %v", err)
+	}
+
+	p.queue = make(chan *Message, p.conf.Pipeline)
+	go p.run()
+}
+
+// Start fires a publisher listener
+func (p *RedisPublisher) Start(in <-chan *Message) {
+	for msg := range in {
+		p.queue <- msg
+	}
+}
+
+// run drains the queue in batches of up to conf.Pipeline messages,
+// each batch is written in a single round trip
+func (p *RedisPublisher) run() {
+	batch := make([]*Message, 0, p.conf.Pipeline)
+	for msg := range p.queue {
+		batch = append(batch[:0], msg)
+	drain:
+		for len(batch) < p.conf.Pipeline {
+			select {
+			case m := <-p.queue:
+				batch = append(batch, m)
+			default:
+				break drain
+			}
+		}
+		p.publish(batch)
+	}
+}
+
+// publish sends the batch, reconnecting with a growing backoff for as long as
+// the server is unreachable
+func (p *RedisPublisher) publish(batch []*Message) {
+	backoff := 100 * time.Millisecond
+	for {
+		err := p.connect()
+		if err == nil {
+			var n int
+			n, err = p.send(batch)
+			batch = batch[n:]
+		}
+		if err == nil {
+			return
+		}
+		logger.Errorf("Error on Redis send for [%s]: %v", p.stream, err)
+		p.disconnect()
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > redisMaxBackoff {
+			backoff = redisMaxBackoff
+		}
+	}
+}
+
+// send pipelines an XADD per message and returns how many of them got a reply,
+// errors replied for single messages are logged and do not fail the batch
+func (p *RedisPublisher) send(batch []*Message) (int, error) {
+	for _, msg := range batch {
+		if err := p.write(p.xadd(msg)...); err != nil {
+			return 0, err
+		}
+	}
+	if err := p.w.Flush(); err != nil {
+		return 0, err
+	}
+	for i, msg := range batch {
+		reply, err := p.read()
+		if e, ok := err.(redisError); ok {
+			logger.Message(msg).Errorf("Error on Redis XADD for [%s]: %v", p.stream, e)
+			recordDelivery("redis", p.stream, msg, e)
+			msg.Failed(e, 1)
+			continue
+		}
+		if err != nil {
+			return i, err
+		}
+		recordDelivery("redis", p.stream, msg, nil)
+		msg.Delivered(nil)
+		logger.Message(msg).Debugf("Stream[%s] < %s as %v", p.stream, logBody(msg.Body), reply)
+	}
+	return len(batch), nil
+}
+
+// health reports whether the publisher is connected to the server
+func (p *RedisPublisher) health(status map[string]error) {
+	if atomic.LoadInt32(&p.connected) == 0 {
+		status["redis"] = fmt.Errorf("not connected to %s", p.conf.Address)
+		return
+	}
+	status["redis"] = nil
+}
+
+func (p *RedisPublisher) xadd(msg *Message) []string {
+	cmd := []string{"XADD", p.stream}
+	if p.conf.MaxLen > 0 {
+		cmd = append(cmd, "MAXLEN", "~", strconv.Itoa(p.conf.MaxLen))
+	}
+	return append(cmd, "*",
+		"id", msg.ID,
+		"on", msg.On.Format(time.RFC3339Nano),
+		"body", msg.Body)
+}
+
+func (p *RedisPublisher) connect() error {
+	if p.conn != nil {
+		return nil
+	}
+	conn, err := net.DialTimeout("tcp", p.conf.Address, redisDialTimeout)
+	if err != nil {
+		return err
+	}
+	p.conn = conn
+	p.r = bufio.NewReader(conn)
+	p.w = bufio.NewWriter(conn)
+
+	if len(p.conf.Password) > 0 {
+		if _, err := p.do("AUTH", p.conf.Password); err != nil {
+			p.disconnect()
+			return fmt.Errorf("unable to authenticate: %v", err)
+		}
+	}
+	if p.conf.DB > 0 {
+		if _, err := p.do("SELECT", strconv.Itoa(p.conf.DB)); err != nil {
+			p.disconnect()
+			return fmt.Errorf("unable to select db %d: %v", p.conf.DB, err)
+		}
+	}
+	atomic.StoreInt32(&p.connected, 1)
+	return nil
+}
+
+func (p *RedisPublisher) disconnect() {
+	atomic.StoreInt32(&p.connected, 0)
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+}
+
+func (p *RedisPublisher) do(cmd ...string) (interface{}, error) {
+	if err := p.write(cmd...); err != nil {
+		return nil, err
+	}
+	if err := p.w.Flush(); err != nil {
+		return nil, err
+	}
+	return p.read()
+}
+
+// write encodes the command as a RESP array of bulk strings
+func (p *RedisPublisher) write(cmd ...string) error {
+	fmt.Fprintf(p.w, "*%d\r\n", len(cmd))
+	for _, arg := range cmd {
+		fmt.Fprintf(p.w, "$%d\r\n", len(arg))
+		p.w.WriteString(arg)
+		if _, err := p.w.WriteString("\r\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *RedisPublisher) read() (interface{}, error) {
+	return readRESP(p.r)
+}
+
+// readRESP parses a single RESP value, error replies are returned as redisError
+func readRESP(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errors.New("malformed RESP line")
+	}
+	kind, line := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return line, nil
+	case '-':
+		return nil, redisError(line)
+	case ':':
+		return strconv.ParseInt(line, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = readRESP(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("unexpected RESP type: %q", kind)
+}
@@ -0,0 +1,116 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRedis accepts a single connection and replies to each command,
+// commands are reported on the returned channel
+func fakeRedis(t *testing.T) (net.Listener, <-chan []string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmds := make(chan []string, 10)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for seq := 1; ; seq++ {
+			v, err := readRESP(r)
+			if err != nil {
+				return
+			}
+			var cmd []string
+			for _, arg := range v.([]interface{}) {
+				cmd = append(cmd, arg.(string))
+			}
+			if cmd[0] == "XADD" && cmd[len(cmd)-1] == "bad" {
+				fmt.Fprint(conn, "-ERR rejected\r\n")
+			} else if cmd[0] == "XADD" {
+				fmt.Fprintf(conn, "$3\r\n%d-0\r\n", seq)
+			} else {
+				fmt.Fprint(conn, "+OK\r\n")
+			}
+			cmds <- cmd
+		}
+	}()
+	return l, cmds
+}
+
+func TestRedisPublisher_Publish(t *testing.T) {
+	l, cmds := fakeRedis(t)
+	defer l.Close()
+
+	p := NewRedisPublisher().(*RedisPublisher)
+	p.Config("test", &PubConfig{
+		Topic: "messages",
+		Redis: RedisConfig{
+			Address:  l.Addr().String(),
+			Password: "secret",
+			Stream:   "gateway:{topic}",
+			MaxLen:   1000,
+		},
+	})
+
+	assert.Equal(t, []string{"AUTH", "secret"}, <-cmds)
+
+	in := make(chan *Message)
+	go p.Start(in)
+
+	bad := NewMessage("bad")
+	in <- bad
+	msg := NewMessage("hello")
+	in <- msg
+
+	for _, m := range []*Message{bad, msg} {
+		select {
+		case cmd := <-cmds:
+			assert.Equal(t, []string{
+				"XADD", "gateway:messages", "MAXLEN", "~", "1000", "*",
+				"id", m.ID,
+				"on", m.On.Format(time.RFC3339Nano),
+				"body", m.Body,
+			}, cmd)
+		case <-time.After(5 * time.Second):
+			t.Fatal("XADD not received")
+		}
+	}
+}
+
+func TestReadRESP(t *testing.T) {
+	r := bufio.NewReader(
+		strings.NewReader("*3\r\n+OK\r\n:42\r\n$5\r\nhello\r\n-ERR boom\r\n"))
+
+	v, err := readRESP(r)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"OK", int64(42), "hello"}, v)
+
+	_, err = readRESP(r)
+	assert.Equal(t, redisError("ERR boom"), err)
+}
@@ -0,0 +1,111 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// closeGoingAway is the WebSocket close code of a server going down
+	closeGoingAway = 1001
+
+	defaultShutdownTimeout = 10
+	shutdownPoll           = 50 * time.Millisecond
+	shutdownGrace          = time.Second
+)
+
+// inflight counts the messages sent by clients and not yet delivered
+var inflight int64
+
+// shutdown stops accepting connections, asks the clients to go away, waits
+// for the in-flight messages to be delivered and flushes the publisher, the
+// whole sequence is bounded by the timeout
+func shutdown(srv *http.Server, b *broker, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Errorf("Error on server shutdown: %v", err)
+	}
+
+	if err := downlink.Close(); err != nil {
+		logger.Errorf("Error on downlink close: %v", err)
+	}
+	// clients not closing their side in time are cut off
+	grace := disconnectGrace
+	if left := time.Until(deadline); left < grace {
+		grace = left
+	}
+	clients := b.list()
+	logger.Infof("closing %d clients...", len(clients))
+	for _, c := range clients {
+		c.disconnect(closeGoingAway, grace)
+	}
+
+	if !drain(deadline) {
+		logger.Warnf("%d messages were not delivered in time", atomic.LoadInt64(&inflight))
+	}
+
+	// the producer gets a moment to flush even when the deadline passed
+	if until := time.Now().Add(shutdownGrace); until.After(deadline) {
+		deadline = until
+	}
+	done := make(chan bool)
+	go func() {
+		// the workers hand the queued messages on before the publisher closes
+		b.pipeline.close()
+		if err := closePublisher(pub); err != nil {
+			logger.Errorf("Error on publisher close: %v", err)
+		}
+		if err := closePublisher(willPub); err != nil {
+			logger.Errorf("Error on last will publisher close: %v", err)
+		}
+		if err := presence.Close(); err != nil {
+			logger.Errorf("Error on presence close: %v", err)
+		}
+		if err := tracer.Close(); err != nil {
+			logger.Errorf("Error on tracer close: %v", err)
+		}
+		if err := rates.Close(); err != nil {
+			logger.Errorf("Error on usage save: %v", err)
+		}
+		if err := deadLetters.Close(); err != nil {
+			logger.Errorf("Error on dead-letter queue close: %v", err)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Until(deadline)):
+		logger.Warnf("publisher did not flush in time")
+	}
+}
+
+// drain waits for the in-flight messages to be delivered until the deadline
+func drain(deadline time.Time) bool {
+	for atomic.LoadInt64(&inflight) > 0 {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(shutdownPoll)
+	}
+	return true
+}
@@ -0,0 +1,97 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"encoding/binary"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"code.google.com/p/go.net/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShutdown(t *testing.T) {
+	defer func(p Publisher) { pub = p }(pub)
+	gate := newGatePublisher()
+	pub = gate
+
+	ts, b := newTestServer(t)
+	defer ts.Close()
+
+	ws := dialTestServer(t, ts, "/")
+	defer ws.Close()
+	ws.SetDeadline(time.Now().Add(5 * time.Second))
+	assert.Nil(t, websocket.Message.Send(ws, "in flight"))
+	for i := 0; i < 100 && atomic.LoadInt64(&inflight) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the message is delivered once the backend is back
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		close(gate.gate)
+		(<-gate.out).Delivered(nil)
+	}()
+
+	start := time.Now()
+	shutdown(&http.Server{}, b, 5*time.Second)
+	assert.Equal(t, int64(0), atomic.LoadInt64(&inflight), "Shutdown must wait for in-flight messages")
+	assert.True(t, time.Since(start) >= 100*time.Millisecond)
+	assert.True(t, time.Since(start) < 5*time.Second)
+
+	// the client is asked to go away
+	frame, err := ws.NewFrameReader()
+	if assert.Nil(t, err) {
+		assert.Equal(t, byte(websocket.CloseFrame), frame.PayloadType())
+		var code [2]byte
+		frame.Read(code[:])
+		assert.Equal(t, uint16(closeGoingAway), binary.BigEndian.Uint16(code[:]))
+	}
+}
+
+func TestDrain(t *testing.T) {
+	atomic.AddInt64(&inflight, 1)
+	assert.False(t, drain(time.Now().Add(100*time.Millisecond)), "Drain must give up at the deadline")
+	atomic.AddInt64(&inflight, -1)
+	assert.True(t, drain(time.Now()))
+}
+
+func TestShutdown_CutOff(t *testing.T) {
+	ts, b := newTestServer(t)
+	defer ts.Close()
+
+	ws := dialTestServer(t, ts, "/")
+	defer ws.Close()
+	for i := 0; i < 100 && len(b.list()) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the client never answers the close frame
+	shutdown(&http.Server{}, b, 200*time.Millisecond)
+	ws.SetDeadline(time.Now().Add(5 * time.Second))
+	var msg []byte
+	err := websocket.Message.Receive(ws, &msg)
+	for err == nil {
+		err = websocket.Message.Receive(ws, &msg)
+	}
+	for i := 0; i < 100 && len(b.list()) > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Empty(t, b.list(), "Clients not closing their side must be cut off")
+}
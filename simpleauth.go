@@ -17,7 +17,6 @@ package main
 
 import (
 	"encoding/base64"
-	"log"
 	"net/http"
 	"strings"
 )
@@ -36,12 +35,12 @@ func NewSimpleAuth(token string) Authenticator {
 func (a *SimpleAuth) Validate(req *http.Request) bool {
 	auths, _ := req.Header["Authorization"]
 	if len(auths) != 1 {
-		log.Println("missing Authorization")
+		logger.With("remote", req.RemoteAddr).Warnf("missing Authorization")
 		return false
 	}
 	tokens := strings.Split(auths[0], " ")
 	if len(tokens) != 2 || tokens[0] != "Bearer" {
-		log.Printf("invalid auth type: %s", tokens)
+		logger.With("remote", req.RemoteAddr).Warnf("invalid auth type")
 		return false
 	}
 	token := decode(tokens[1])
@@ -51,7 +50,7 @@ func (a *SimpleAuth) Validate(req *http.Request) bool {
 func decode(val string) string {
 	raw, err := base64.StdEncoding.DecodeString(val)
 	if err != nil {
-		log.Printf("unable to decode: %s", val)
+		logger.Warnf("unable to decode token")
 		return ""
 	}
 	return string(raw)
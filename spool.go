@@ -0,0 +1,650 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	spoolExt              = ".spool"
+	spoolCursor           = "cursor"
+	spoolRecordHeaderSize = 8
+	defaultSpoolMaxMB     = 256
+	defaultSpoolSegmentMB = 16
+	defaultSpoolTimeout   = 100
+
+	// spoolReplayWindow is how many replayed messages may wait for the
+	// wrapped publisher at once
+	spoolReplayWindow = 64
+)
+
+var (
+	spoolRetryDelay = time.Second
+
+	spoolSpooled  = newCounter("gateway_spool_spooled_total", "Number of messages written to the spool.")
+	spoolReplayed = newCounter("gateway_spool_replayed_total", "Number of spooled messages delivered by the backend.")
+	spoolDropped  = newCounter("gateway_spool_dropped_total", "Number of messages the spool had no room for.")
+
+	errSpoolFull    = errors.New("spool is full")
+	errSpoolCorrupt = errors.New("spool record is corrupt")
+)
+
+// spoolRecord is the spooled form of a message, it keeps the message
+// metadata which is not part of its JSON representation
+type spoolRecord struct {
+	*Message
+	Device string `json:"device,omitempty"`
+	Auth   string `json:"auth,omitempty"`
+	Trace  string `json:"trace,omitempty"`
+}
+
+// spoolReplay is a replayed message waiting for the wrapped publisher,
+// the cursor moves past it once it and the messages before it are delivered
+type spoolReplay struct {
+	seq  int64
+	size int64
+	done bool
+	err  error
+}
+
+// SpoolPublisher is the type representing the on-disk spool, it wraps another
+// publisher and absorbs the messages that publisher is not able to take in time.
+// Spooled messages are written to segment files and replayed in order once the
+// wrapped publisher keeps up again, including after a gateway restart
+type SpoolPublisher struct {
+	inner   Publisher
+	conf    SpoolConfig
+	timeout time.Duration
+	out     chan *Message
+
+	mu        sync.Mutex
+	ready     *sync.Cond
+	counts    map[int64]int64
+	depth     int64
+	bytes     int64
+	writeSeq  int64
+	writeSize int64
+	writer    *os.File
+	dirty     bool
+	readSeq   int64
+	readOff   int64
+	cursor    *os.File
+	pending   []*spoolReplay
+	failed    bool
+	closed    bool
+}
+
+// NewSpoolPublisher creates a new spool in front of the passed publisher
+func NewSpoolPublisher(inner Publisher) Publisher {
+	return &SpoolPublisher{inner: inner}
+}
+
+// Config configures the wrapped publisher and opens the spool, replay of any
+// messages left over from the previous run starts right away
+func (p *SpoolPublisher) Config(clientID string, args *PubConfig) {
+	p.conf = args.Spool
+	if len(p.conf.Dir) == 0 {
+		p.conf.Dir = "./spool"
+	}
+	if p.conf.MaxSizeMB <= 0 {
+		p.conf.MaxSizeMB = defaultSpoolMaxMB
+	}
+	if p.conf.SegmentMB <= 0 {
+		p.conf.SegmentMB = defaultSpoolSegmentMB
+	}
+	if p.conf.Timeout <= 0 {
+		p.conf.Timeout = defaultSpoolTimeout
+	}
+	p.timeout = time.Duration(p.conf.Timeout) * time.Millisecond
+	p.ready = sync.NewCond(&p.mu)
+	p.counts = make(map[int64]int64)
+
+	if err := os.MkdirAll(p.conf.Dir, 0755); err != nil {
+		logger.Fatalf("Failed to create spool directory: %v", err)
+	}
+	if err := p.load(); err != nil {
+		logger.Fatalf("Failed to load spool: %v", err)
+	}
+	if p.depth > 0 {
+		logger.Infof("Spool[%s] has %d messages to replay", p.conf.Dir, p.depth)
+	}
+
+	newGaugeFunc("gateway_spool_messages", "Number of messages waiting in the spool.", func() float64 {
+		return float64(p.Depth())
+	})
+	newGaugeFunc("gateway_spool_bytes", "Size of the spool in bytes.", func() float64 {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return float64(p.bytes)
+	})
+
+	p.inner.Config(clientID, args)
+	p.out = make(chan *Message)
+	go p.inner.Start(p.out)
+	go p.replay()
+	go p.maintain()
+}
+
+// Start fires a publisher listener
+func (p *SpoolPublisher) Start(in <-chan *Message) {
+	timer := time.NewTimer(p.timeout)
+	defer timer.Stop()
+	for msg := range in {
+		// once anything got spooled all of the messages go through the spool
+		// until it is drained, otherwise they would overtake the spooled ones
+		if p.Depth() == 0 {
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(p.timeout)
+			select {
+			case p.out <- p.forward(msg):
+				continue
+			case <-timer.C:
+			}
+		}
+		// spooled messages count as delivered, the spool replays them
+		// even after a restart when nobody is waiting for them anymore
+		msg.Delivered(p.spool(msg))
+	}
+}
+
+// forward returns the message handed to the wrapped publisher right away,
+// a delivery the publisher fails is spooled rather than failing the message
+func (p *SpoolPublisher) forward(msg *Message) *Message {
+	fwd := *msg
+	fwd.spooled = true
+	fwd.delivered = func(err error) {
+		if err != nil {
+			logger.Message(msg).Warnf("Spooling failed delivery: %v", err)
+			err = p.spool(msg)
+		}
+		msg.Delivered(err)
+	}
+	return &fwd
+}
+
+// spool writes the message to the spool, messages it has no room for
+// are dead-lettered
+func (p *SpoolPublisher) spool(msg *Message) error {
+	if err := p.append(msg); err != nil {
+		spoolDropped.with().inc()
+		logger.Message(msg).Errorf("Error on spool write: %v", err)
+		deadLetters.Send(msg, stageDelivery, err, 1)
+		return err
+	}
+	spoolSpooled.with().inc()
+	logger.Message(msg).Debugf("Spool[%s] < %s", p.conf.Dir, logBody(msg.Body))
+	return nil
+}
+
+// Depth returns the number of messages waiting in the spool
+func (p *SpoolPublisher) Depth() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.depth
+}
+
+// health reports whether the spool has room left and the state of
+// the wrapped publisher
+func (p *SpoolPublisher) health(status map[string]error) {
+	status["spool"] = nil
+	if p.Full() {
+		status["spool"] = errSpoolFull
+	}
+	checkHealth(p.inner, status)
+}
+
+// Full reports whether the spool reached its configured size
+func (p *SpoolPublisher) Full() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.bytes >= int64(p.conf.MaxSizeMB)<<20
+}
+
+// Close flushes the spool to disk, messages not yet replayed stay in the
+// spool for the next run, and closes the wrapped publisher
+func (p *SpoolPublisher) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	p.ready.Broadcast()
+	err := p.writer.Sync()
+	if cerr := p.writer.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := p.cursor.Close(); err == nil {
+		err = cerr
+	}
+	p.mu.Unlock()
+
+	if cerr := closePublisher(p.inner); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (p *SpoolPublisher) append(msg *Message) error {
+	b, err := json.Marshal(&spoolRecord{msg, msg.Device, msg.Auth, msg.Trace})
+	if err != nil {
+		return err
+	}
+	rec := make([]byte, spoolRecordHeaderSize+len(b))
+	binary.BigEndian.PutUint32(rec[0:4], uint32(len(b)))
+	binary.BigEndian.PutUint32(rec[4:8], crc32.ChecksumIEEE(b))
+	copy(rec[spoolRecordHeaderSize:], b)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return errors.New("spool is closed")
+	}
+	if p.bytes+int64(len(rec)) > int64(p.conf.MaxSizeMB)<<20 {
+		return errSpoolFull
+	}
+	if p.writeSize > 0 && p.writeSize+int64(len(rec)) > int64(p.conf.SegmentMB)<<20 {
+		if err := p.roll(); err != nil {
+			return err
+		}
+	}
+	n, err := p.writer.Write(rec)
+	p.writeSize += int64(n)
+	if err != nil {
+		return err
+	}
+	p.dirty = true
+	p.counts[p.writeSeq]++
+	p.depth++
+	p.bytes += int64(len(rec))
+	p.ready.Signal()
+	return nil
+}
+
+// roll starts a new segment, must be called with the lock held
+func (p *SpoolPublisher) roll() error {
+	if err := p.writer.Sync(); err != nil {
+		return err
+	}
+	if err := p.writer.Close(); err != nil {
+		return err
+	}
+	return p.openWriter(p.writeSeq + 1)
+}
+
+func (p *SpoolPublisher) openWriter(seq int64) error {
+	f, err := os.OpenFile(p.segment(seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	p.writer = f
+	p.writeSeq = seq
+	p.writeSize = info.Size()
+	return nil
+}
+
+// replay hands the spooled messages over to the wrapped publisher in order.
+// The cursor only moves past a message once the publisher delivered it, a
+// failed message is replayed again along with the ones sent after it
+func (p *SpoolPublisher) replay() {
+	var f *os.File
+	var r *bufio.Reader
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+	}()
+
+	p.mu.Lock()
+	seq, off := p.readSeq, p.readOff
+	for {
+		p.settle()
+		if p.closed {
+			p.mu.Unlock()
+			return
+		}
+		if p.failed && p.settled() {
+			// rewind to the failed message
+			p.failed = false
+			p.pending = nil
+			seq, off = p.readSeq, p.readOff
+			if f != nil {
+				f.Close()
+				f = nil
+			}
+			p.mu.Unlock()
+			time.Sleep(spoolRetryDelay)
+			p.mu.Lock()
+			continue
+		}
+		if p.failed || len(p.pending) >= spoolReplayWindow || p.depth <= int64(len(p.pending)) {
+			p.ready.Wait()
+			continue
+		}
+		p.mu.Unlock()
+
+		if f == nil {
+			var err error
+			if f, err = os.Open(p.segment(seq)); err == nil {
+				_, err = f.Seek(off, os.SEEK_SET)
+			}
+			if err != nil {
+				logger.Errorf("Error on spool replay of segment %d: %v", seq, err)
+				f = nil
+				seq, off = p.next(seq)
+				p.mu.Lock()
+				continue
+			}
+			r = bufio.NewReader(f)
+		}
+
+		b, err := readSpoolRecord(r)
+		if err != nil {
+			if err != io.EOF {
+				logger.Errorf("Error on spool replay of segment %d: %v", seq, err)
+			}
+			f.Close()
+			f = nil
+			seq, off = p.next(seq)
+			p.mu.Lock()
+			continue
+		}
+
+		size := int64(spoolRecordHeaderSize + len(b))
+		off += size
+		rec := &spoolRecord{Message: &Message{}}
+		if err := json.Unmarshal(b, rec); err != nil {
+			// nothing to retry, the record is skipped right away
+			logger.Errorf("Error on spool replay of segment %d: %v", seq, err)
+			p.mu.Lock()
+			p.pending = append(p.pending, &spoolReplay{seq: seq, size: size, done: true})
+			continue
+		}
+		rec.Message.Device, rec.Message.Auth = rec.Device, rec.Auth
+		rec.Message.Trace = rec.Trace
+		rec.Message.spooled = true
+		replay := &spoolReplay{seq: seq, size: size}
+		rec.Message.delivered = func(err error) {
+			p.mu.Lock()
+			replay.done, replay.err = true, err
+			p.ready.Broadcast()
+			p.mu.Unlock()
+		}
+
+		p.mu.Lock()
+		p.pending = append(p.pending, replay)
+		p.mu.Unlock()
+		p.out <- rec.Message
+		p.mu.Lock()
+	}
+}
+
+// settle moves the cursor past the delivered messages at the head of the
+// pending ones, must be called with the lock held
+func (p *SpoolPublisher) settle() {
+	moved := false
+	for len(p.pending) > 0 && p.pending[0].done && !p.failed {
+		replay := p.pending[0]
+		if replay.err != nil {
+			logger.Errorf("Error on spool replay, retrying: %v", replay.err)
+			p.failed = true
+			break
+		}
+		p.pending = p.pending[1:]
+		p.readOff += replay.size
+		p.counts[replay.seq]--
+		p.depth--
+		p.bytes -= replay.size
+		spoolReplayed.with().inc()
+		moved = true
+	}
+	if moved && !p.closed {
+		p.saveCursor()
+	}
+}
+
+// settled tells whether the publisher is done with all of the pending
+// messages, must be called with the lock held
+func (p *SpoolPublisher) settled() bool {
+	for _, replay := range p.pending {
+		if !replay.done {
+			return false
+		}
+	}
+	return true
+}
+
+// next waits for the messages read from the segment to be delivered before
+// it drops the segment, it returns the position to read from next
+func (p *SpoolPublisher) next(seq int64) (int64, int64) {
+	p.mu.Lock()
+	for {
+		p.settle()
+		if p.closed || p.failed || len(p.pending) == 0 {
+			break
+		}
+		p.ready.Wait()
+	}
+	// after a failure the segment is replayed again from the cursor
+	skip := !p.closed && !p.failed
+	p.mu.Unlock()
+	if skip {
+		p.skip(seq)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.readSeq, p.readOff
+}
+
+// skip drops whatever is left of the segment and moves on to the next one
+func (p *SpoolPublisher) skip(seq int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if seq == p.writeSeq {
+		// the segment being written to ends early only when it is corrupt,
+		// new messages have to land in a fresh segment before dropping it
+		if err := p.roll(); err != nil {
+			logger.Errorf("Error on spool segment rollover %d: %v", seq, err)
+			return
+		}
+	}
+	if info, err := os.Stat(p.segment(seq)); err == nil {
+		p.bytes -= info.Size() - p.readOff
+	}
+	p.depth -= p.counts[seq]
+	delete(p.counts, seq)
+	if err := os.Remove(p.segment(seq)); err != nil && !os.IsNotExist(err) {
+		logger.Errorf("Error on spool segment removal %d: %v", seq, err)
+	}
+	p.readSeq, p.readOff = seq+1, 0
+	p.saveCursor()
+}
+
+// maintain flushes the spooled messages to disk once a second
+func (p *SpoolPublisher) maintain() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return
+		}
+		if p.dirty {
+			p.dirty = false
+			if err := p.writer.Sync(); err != nil {
+				logger.Errorf("Error on spool sync: %v", err)
+			}
+		}
+		p.mu.Unlock()
+	}
+}
+
+func (p *SpoolPublisher) segment(seq int64) string {
+	return filepath.Join(p.conf.Dir, fmt.Sprintf("%020d%s", seq, spoolExt))
+}
+
+// saveCursor records the replay position, must be called with the lock held
+func (p *SpoolPublisher) saveCursor() {
+	if _, err := p.cursor.WriteAt([]byte(fmt.Sprintf("%020d %020d\n", p.readSeq, p.readOff)), 0); err != nil {
+		logger.Errorf("Error on spool cursor write: %v", err)
+	}
+}
+
+// load restores the spool state left by the previous run
+func (p *SpoolPublisher) load() error {
+	cursor, err := os.OpenFile(filepath.Join(p.conf.Dir, spoolCursor), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	p.cursor = cursor
+
+	var seq, off int64
+	if _, err := fmt.Fscanf(cursor, "%d %d\n", &seq, &off); err != nil && err != io.EOF {
+		return fmt.Errorf("unable to read spool cursor: %v", err)
+	}
+
+	names, err := filepath.Glob(filepath.Join(p.conf.Dir, "*"+spoolExt))
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	var segments []int64
+	for _, name := range names {
+		s, err := strconv.ParseInt(strings.TrimSuffix(filepath.Base(name), spoolExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		if s < seq {
+			// fully replayed before the previous run stopped
+			os.Remove(name)
+			continue
+		}
+		segments = append(segments, s)
+	}
+
+	if len(segments) == 0 || segments[0] != seq {
+		off = 0
+	}
+	if len(segments) > 0 {
+		seq = segments[0]
+	}
+	p.readSeq, p.readOff = seq, off
+
+	for i, s := range segments {
+		start := int64(0)
+		if s == p.readSeq {
+			start = off
+		}
+		count, end, err := countSpoolRecords(p.segment(s), start)
+		if err != nil {
+			return err
+		}
+		if i == len(segments)-1 {
+			// drop a record partially written when the previous run stopped
+			if err := os.Truncate(p.segment(s), end); err != nil {
+				return err
+			}
+		}
+		info, err := os.Stat(p.segment(s))
+		if err != nil {
+			return err
+		}
+		p.counts[s] = count
+		p.depth += count
+		p.bytes += info.Size() - start
+	}
+
+	writeSeq := seq
+	if len(segments) > 0 {
+		writeSeq = segments[len(segments)-1]
+	}
+	if err := p.openWriter(writeSeq); err != nil {
+		return err
+	}
+	p.saveCursor()
+	return nil
+}
+
+// countSpoolRecords counts the good records of a segment starting at the offset,
+// returning the offset right after the last good one
+func countSpoolRecords(path string, off int64) (int64, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(off, os.SEEK_SET); err != nil {
+		return 0, 0, err
+	}
+	r := bufio.NewReader(f)
+	var count int64
+	for {
+		b, err := readSpoolRecord(r)
+		if err != nil {
+			return count, off, nil
+		}
+		count++
+		off += int64(spoolRecordHeaderSize + len(b))
+	}
+}
+
+func readSpoolRecord(r *bufio.Reader) ([]byte, error) {
+	header := make([]byte, spoolRecordHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	b := make([]byte, binary.BigEndian.Uint32(header[0:4]))
+	if _, err := io.ReadFull(r, b); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(b) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, errSpoolCorrupt
+	}
+	return b, nil
+}
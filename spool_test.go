@@ -0,0 +1,208 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// gatePublisher takes no messages until its gate is opened
+type gatePublisher struct {
+	gate chan bool
+	out  chan *Message
+}
+
+func newGatePublisher() *gatePublisher {
+	return &gatePublisher{gate: make(chan bool), out: make(chan *Message, 10)}
+}
+
+func (p *gatePublisher) Config(clientID string, args *PubConfig) {}
+func (p *gatePublisher) Start(in <-chan *Message) {
+	<-p.gate
+	for msg := range in {
+		p.out <- msg
+	}
+}
+
+func newTestSpool(t *testing.T, dir string, inner Publisher) *SpoolPublisher {
+	p := NewSpoolPublisher(inner).(*SpoolPublisher)
+	p.Config("test", &PubConfig{Spool: SpoolConfig{Dir: dir, Timeout: 10}})
+	return p
+}
+
+func sendAll(p Publisher, msgs []*Message) {
+	in := make(chan *Message)
+	done := make(chan bool)
+	go func() {
+		p.Start(in)
+		done <- true
+	}()
+	for _, msg := range msgs {
+		in <- msg
+	}
+	close(in)
+	<-done
+}
+
+func receiveAll(t *testing.T, out <-chan *Message, msgs []*Message) {
+	for _, msg := range msgs {
+		select {
+		case got := <-out:
+			assert.Equal(t, msg.ID, got.ID, "Messages must be replayed in order")
+			assert.Equal(t, msg.Body, got.Body)
+			assert.Equal(t, msg.Device, got.Device, "Message metadata must be spooled")
+			got.Delivered(nil)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("msg[%s] was not replayed", msg.ID)
+		}
+	}
+}
+
+func TestSpoolPublisher_Replay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gateway-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	inner := newGatePublisher()
+	p := newTestSpool(t, dir, inner)
+
+	msgs := []*Message{NewMessage("one"), NewMessage("two"), NewMessage("three")}
+	sendAll(p, msgs)
+	assert.Equal(t, int64(3), p.Depth(), "Messages must be spooled while the publisher is unavailable")
+	status := make(map[string]error)
+	checkHealth(p, status)
+	assert.Nil(t, status["spool"], "Spool with room left must be ready")
+
+	close(inner.gate)
+	receiveAll(t, inner.out, msgs)
+
+	for i := 0; i < 100 && p.Depth() > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, int64(0), p.Depth(), "Spool must be drained after replay")
+	assert.Nil(t, p.Close())
+}
+
+func TestSpoolPublisher_Restart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gateway-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := newTestSpool(t, dir, newGatePublisher())
+	msgs := []*Message{NewMessage("one"), NewMessage("two")}
+	msgs[0].Device = "dev1"
+	sendAll(p, msgs)
+	assert.Nil(t, p.Close())
+
+	inner := newGatePublisher()
+	close(inner.gate)
+	p = newTestSpool(t, dir, inner)
+	receiveAll(t, inner.out, msgs)
+	assert.Nil(t, p.Close())
+}
+
+func TestSpoolPublisher_ReplayFailure(t *testing.T) {
+	defer func(d time.Duration) { spoolRetryDelay = d }(spoolRetryDelay)
+	spoolRetryDelay = time.Millisecond
+	dir, err := ioutil.TempDir("", "gateway-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	inner := newGatePublisher()
+	p := newTestSpool(t, dir, inner)
+	msgs := []*Message{NewMessage("one"), NewMessage("two")}
+	sendAll(p, msgs)
+	close(inner.gate)
+
+	// the second message is delivered but the first one failed
+	first, second := <-inner.out, <-inner.out
+	second.Delivered(nil)
+	first.Delivered(errors.New("boom"))
+	assert.Equal(t, int64(2), p.Depth(), "Failed messages must stay in the spool")
+
+	receiveAll(t, inner.out, msgs)
+	for i := 0; i < 100 && p.Depth() > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, int64(0), p.Depth(), "Spool must be drained once the messages are delivered")
+	assert.Nil(t, p.Close())
+}
+
+func TestSpoolPublisher_DeliveryFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gateway-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	inner := newGatePublisher()
+	close(inner.gate)
+	p := newTestSpool(t, dir, inner)
+	msg := NewMessage("one")
+	result := make(chan error, 1)
+	msg.delivered = func(err error) { result <- err }
+	sendAll(p, []*Message{msg})
+
+	// the publisher took the message right away but fails it
+	got := <-inner.out
+	assert.True(t, got.spooled, "Messages retried by the spool must not be dead-lettered")
+	assert.Equal(t, int64(0), p.Depth())
+	got.Delivered(errors.New("boom"))
+	assert.Nil(t, <-result, "Failed deliveries must be spooled")
+	assert.Equal(t, int64(1), p.Depth())
+
+	receiveAll(t, inner.out, []*Message{msg})
+	for i := 0; i < 100 && p.Depth() > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, int64(0), p.Depth(), "Spooled failures must be replayed")
+	assert.Nil(t, p.Close())
+}
+
+func TestSpoolPublisher_RestartUndelivered(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gateway-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	inner := newGatePublisher()
+	p := newTestSpool(t, dir, inner)
+	msgs := []*Message{NewMessage("one")}
+	sendAll(p, msgs)
+	close(inner.gate)
+	<-inner.out
+	assert.Nil(t, p.Close())
+
+	// the message was never delivered, so it is replayed after a restart
+	inner = newGatePublisher()
+	close(inner.gate)
+	p = newTestSpool(t, dir, inner)
+	receiveAll(t, inner.out, msgs)
+	assert.Nil(t, p.Close())
+}
@@ -0,0 +1,422 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	mrand "math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// span kinds as defined by OTLP
+const (
+	spanInternal = 1
+	spanServer   = 2
+	spanClient   = 3
+	spanProducer = 4
+)
+
+const (
+	defaultTracingEndpoint = "http://localhost:4318"
+	defaultTracingService  = "gateway"
+	defaultTracingBatch    = 512
+	defaultTracingBuffer   = 2048
+	defaultTracingSample   = 100
+	tracingFlushFreq       = 5 * time.Second
+)
+
+var (
+	spansExported = newCounter("gateway_spans_total", "Number of spans by export result.", "result")
+
+	// tracer exports the ended spans, tracing is off by default
+	tracer = &Tracer{}
+)
+
+// Span is a timed operation of a trace
+type Span struct {
+	name    string
+	kind    int
+	trace   [16]byte
+	id      [8]byte
+	parent  [8]byte
+	sampled bool
+	start   time.Time
+	end     time.Time
+	attrs   []spanAttr
+	err     error
+	ended   int32
+}
+
+type spanAttr struct {
+	key   string
+	value interface{}
+}
+
+type spanKey struct{}
+
+// startSpan starts a span as child of parent or of a new trace
+// when parent is nil, no span is started while tracing is off
+func startSpan(name string, kind int, parent *Span) *Span {
+	if !tracer.enabled() {
+		return nil
+	}
+	s := &Span{name: name, kind: kind, start: time.Now()}
+	if parent != nil {
+		s.trace = parent.trace
+		s.parent = parent.id
+		s.sampled = parent.sampled
+	} else {
+		rand.Read(s.trace[:])
+		s.sampled = tracer.sampled()
+	}
+	rand.Read(s.id[:])
+	return s
+}
+
+// remoteSpan returns the span of the W3C traceparent header value so
+// spans can continue the trace of the caller, nil when it is not valid
+func remoteSpan(traceparent string) *Span {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return nil
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return nil
+	}
+	s := &Span{ended: 1}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return nil
+	}
+	if _, err := hex.Decode(s.trace[:], []byte(parts[1])); err != nil {
+		return nil
+	}
+	if _, err := hex.Decode(s.id[:], []byte(parts[2])); err != nil {
+		return nil
+	}
+	if s.trace == [16]byte{} || s.id == [8]byte{} {
+		return nil
+	}
+	s.sampled = flags[0]&1 == 1
+	return s
+}
+
+// contextWithSpan returns a copy of ctx carrying the span
+func contextWithSpan(ctx context.Context, s *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, s)
+}
+
+// spanFromContext returns the span carried by ctx, nil if there is none
+func spanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// SetAttr sets the attribute of the span, the value is a string, a number
+// or a bool
+func (s *Span) SetAttr(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.attrs = append(s.attrs, spanAttr{key, value})
+}
+
+// End ends the span with the outcome of the operation and hands it
+// to the exporter, spans are ended once
+func (s *Span) End(err error) {
+	if s == nil || !atomic.CompareAndSwapInt32(&s.ended, 0, 1) {
+		return
+	}
+	s.end = time.Now()
+	s.err = err
+	if s.sampled {
+		tracer.export(s)
+	}
+}
+
+// Traceparent returns the W3C traceparent header value of the span
+func (s *Span) Traceparent() string {
+	if s == nil {
+		return ""
+	}
+	flags := 0
+	if s.sampled {
+		flags = 1
+	}
+	return fmt.Sprintf("00-%x-%x-%02x", s.trace, s.id, flags)
+}
+
+// SpanExporter sends the ended spans to a tracing backend
+type SpanExporter interface {
+	Export(spans []*Span) error
+}
+
+// Tracer batches the ended spans to its exporter
+type Tracer struct {
+	exporter SpanExporter
+	sample   int
+	spans    chan *Span
+	done     chan bool
+	mu       sync.RWMutex
+	closed   bool
+}
+
+// newTracer creates a tracer exporting to exporter the spans of percent
+// of the traces started by the gateway
+func newTracer(exporter SpanExporter, percent int) *Tracer {
+	t := &Tracer{
+		exporter: exporter,
+		sample:   percent,
+		spans:    make(chan *Span, defaultTracingBuffer),
+		done:     make(chan bool),
+	}
+	go t.batch()
+	return t
+}
+
+// enabled tells if the tracer has an exporter for the spans
+func (t *Tracer) enabled() bool {
+	return t.exporter != nil
+}
+
+func (t *Tracer) sampled() bool {
+	return t.sample >= 100 || (t.sample > 0 && mrand.Intn(100) < t.sample)
+}
+
+func (t *Tracer) export(s *Span) {
+	if t.exporter == nil {
+		return
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.closed {
+		return
+	}
+	select {
+	case t.spans <- s:
+	default:
+		spansExported.with("dropped").inc()
+	}
+}
+
+// batch exports the spans once the batch is full or every flush period
+func (t *Tracer) batch() {
+	defer close(t.done)
+	ticker := time.NewTicker(tracingFlushFreq)
+	defer ticker.Stop()
+	var spans []*Span
+	flush := func() {
+		if len(spans) == 0 {
+			return
+		}
+		if err := t.exporter.Export(spans); err != nil {
+			logger.Errorf("Error on span export: %v", err)
+			spansExported.with("failed").add(float64(len(spans)))
+		} else {
+			spansExported.with("exported").add(float64(len(spans)))
+		}
+		spans = nil
+	}
+	for {
+		select {
+		case s, ok := <-t.spans:
+			if !ok {
+				flush()
+				return
+			}
+			spans = append(spans, s)
+			if len(spans) >= defaultTracingBatch {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// Close exports the spans ended so far
+func (t *Tracer) Close() error {
+	if t.exporter == nil {
+		return nil
+	}
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	close(t.spans)
+	t.mu.Unlock()
+	<-t.done
+	return nil
+}
+
+// OTLPExporter sends the spans to an OpenTelemetry collector with
+// the OTLP/HTTP JSON encoding
+type OTLPExporter struct {
+	url     string
+	service string
+	client  *http.Client
+}
+
+// NewOTLPExporter creates an exporter to the collector at endpoint
+func NewOTLPExporter(endpoint, service string) SpanExporter {
+	return &OTLPExporter{
+		url:     strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		service: service,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Export posts the spans to the collector
+func (e *OTLPExporter) Export(spans []*Span) error {
+	b, err := json.Marshal(e.request(spans))
+	if err != nil {
+		return err
+	}
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector responded with %s", resp.Status)
+	}
+	return nil
+}
+
+type otlpAttr struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID      string     `json:"traceId"`
+	SpanID       string     `json:"spanId"`
+	ParentSpanID string     `json:"parentSpanId,omitempty"`
+	Name         string     `json:"name"`
+	Kind         int        `json:"kind"`
+	Start        string     `json:"startTimeUnixNano"`
+	End          string     `json:"endTimeUnixNano"`
+	Attributes   []otlpAttr `json:"attributes,omitempty"`
+	Status       otlpStatus `json:"status"`
+}
+
+// request returns the OTLP export request of the spans
+func (e *OTLPExporter) request(spans []*Span) interface{} {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		o := otlpSpan{
+			TraceID: hex.EncodeToString(s.trace[:]),
+			SpanID:  hex.EncodeToString(s.id[:]),
+			Name:    s.name,
+			Kind:    s.kind,
+			Start:   strconv.FormatInt(s.start.UnixNano(), 10),
+			End:     strconv.FormatInt(s.end.UnixNano(), 10),
+		}
+		if s.parent != [8]byte{} {
+			o.ParentSpanID = hex.EncodeToString(s.parent[:])
+		}
+		for _, a := range s.attrs {
+			o.Attributes = append(o.Attributes, otlpAttr{a.key, otlpValue(a.value)})
+		}
+		if s.err != nil {
+			o.Status = otlpStatus{Code: 2, Message: s.err.Error()}
+		}
+		out = append(out, o)
+	}
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": []otlpAttr{
+					{"service.name", otlpValue(e.service)},
+					{"service.instance.id", otlpValue(args.ID)},
+				},
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]string{"name": defaultTracingService},
+				"spans": out,
+			}},
+		}},
+	}
+}
+
+func otlpValue(v interface{}) map[string]interface{} {
+	switch v := v.(type) {
+	case bool:
+		return map[string]interface{}{"boolValue": v}
+	case int:
+		return map[string]interface{}{"intValue": strconv.Itoa(v)}
+	case int64:
+		return map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+	case float64:
+		return map[string]interface{}{"doubleValue": v}
+	}
+	return map[string]interface{}{"stringValue": fmt.Sprint(v)}
+}
+
+// configTracing applies the environment and the defaults to the tracer
+func configTracing(c *TracingConfig) {
+	SetWithStringEnvVar("GATEWAY_TRACING_EXPORTER", &c.Exporter)
+	SetWithStringEnvVar("GATEWAY_TRACING_ENDPOINT", &c.Endpoint)
+	SetWithStringEnvVar("GATEWAY_TRACING_SERVICE", &c.Service)
+	if s := GetEnvVarAsString("GATEWAY_TRACING_SAMPLE_PERCENT", ""); len(s) > 0 {
+		percent := ParseInt(s, defaultTracingSample)
+		c.SamplePercent = &percent
+	}
+	c.Exporter = strings.ToLower(c.Exporter)
+
+	// 0 samples none of the traces, unset samples all of them
+	percent := defaultTracingSample
+	if c.SamplePercent != nil {
+		percent = *c.SamplePercent
+	}
+	if percent < 0 || percent > 100 {
+		log.Panicf("Invalid tracing sample percent: %v", percent)
+	}
+	if len(c.Endpoint) == 0 {
+		c.Endpoint = defaultTracingEndpoint
+	}
+	if len(c.Service) == 0 {
+		c.Service = defaultTracingService
+	}
+	switch c.Exporter {
+	case "", "none":
+		c.Exporter = "none"
+		tracer = &Tracer{}
+	case "otlp":
+		tracer = newTracer(NewOTLPExporter(c.Endpoint, c.Service), percent)
+	default:
+		log.Panicf("Invalid tracing exporter: %v", c.Exporter)
+	}
+}
@@ -0,0 +1,321 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"code.google.com/p/go.net/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingExporter keeps the spans it is handed
+type recordingExporter struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+func (e *recordingExporter) Export(spans []*Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+// byName returns the recorded spans of the trace by their name
+func (e *recordingExporter) byName(trace [16]byte) map[string]*Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	spans := make(map[string]*Span)
+	for _, s := range e.spans {
+		if s.trace == trace {
+			spans[s.name] = s
+		}
+	}
+	return spans
+}
+
+// otlpFields are the fields of the OTLP/JSON trace messages
+// (opentelemetry-proto trace/v1 and common/v1), by message
+var otlpFields = map[string][]string{
+	"request":       {"resourceSpans"},
+	"resourceSpans": {"resource", "scopeSpans", "schemaUrl"},
+	"resource":      {"attributes", "droppedAttributesCount"},
+	"scopeSpans":    {"scope", "spans", "schemaUrl"},
+	"scope":         {"name", "version", "attributes", "droppedAttributesCount"},
+	"span": {"traceId", "spanId", "traceState", "parentSpanId", "flags", "name", "kind",
+		"startTimeUnixNano", "endTimeUnixNano", "attributes", "droppedAttributesCount",
+		"events", "droppedEventsCount", "links", "droppedLinksCount", "status"},
+	"status":   {"message", "code"},
+	"keyValue": {"key", "value"},
+	"anyValue": {"stringValue", "boolValue", "intValue", "doubleValue", "arrayValue", "kvlistValue", "bytesValue"},
+}
+
+var (
+	otlpTraceID = regexp.MustCompile("^[0-9a-f]{32}$")
+	otlpSpanID  = regexp.MustCompile("^[0-9a-f]{16}$")
+	otlpUint64  = regexp.MustCompile("^[0-9]+$")
+	otlpInt64   = regexp.MustCompile("^-?[0-9]+$")
+)
+
+// assertOTLP asserts that v is an OTLP/JSON message of the kind and
+// returns its fields
+func assertOTLP(t *testing.T, kind string, v interface{}) map[string]interface{} {
+	m, ok := v.(map[string]interface{})
+	if !assert.True(t, ok, "%s must be an object: %v", kind, v) {
+		return nil
+	}
+	for k := range m {
+		known := false
+		for _, f := range otlpFields[kind] {
+			known = known || k == f
+		}
+		assert.True(t, known, "Unknown field %s of %s", k, kind)
+	}
+	return m
+}
+
+func assertOTLPAttributes(t *testing.T, v interface{}) {
+	if v == nil {
+		return
+	}
+	for _, a := range v.([]interface{}) {
+		kv := assertOTLP(t, "keyValue", a)
+		assert.NotEmpty(t, kv["key"])
+		value := assertOTLP(t, "anyValue", kv["value"])
+		assert.Len(t, value, 1, "Values must have exactly one type: %v", value)
+		if i, ok := value["intValue"]; ok {
+			assert.Regexp(t, otlpInt64, i, "Integers must be encoded as decimal strings")
+		}
+	}
+}
+
+// assertOTLPRequest asserts that body is a valid OTLP/JSON export trace
+// service request and returns its spans
+func assertOTLPRequest(t *testing.T, body interface{}) (spans []map[string]interface{}) {
+	for _, r := range assertOTLP(t, "request", body)["resourceSpans"].([]interface{}) {
+		rs := assertOTLP(t, "resourceSpans", r)
+		assertOTLPAttributes(t, assertOTLP(t, "resource", rs["resource"])["attributes"])
+		for _, s := range rs["scopeSpans"].([]interface{}) {
+			ss := assertOTLP(t, "scopeSpans", s)
+			assertOTLP(t, "scope", ss["scope"])
+			for _, v := range ss["spans"].([]interface{}) {
+				span := assertOTLP(t, "span", v)
+				assert.Regexp(t, otlpTraceID, span["traceId"])
+				assert.NotEqual(t, strings.Repeat("0", 32), span["traceId"])
+				assert.Regexp(t, otlpSpanID, span["spanId"])
+				assert.NotEqual(t, strings.Repeat("0", 16), span["spanId"])
+				if parent, ok := span["parentSpanId"]; ok {
+					assert.Regexp(t, otlpSpanID, parent)
+				}
+				assert.NotEmpty(t, span["name"])
+				kind, _ := span["kind"].(float64)
+				assert.True(t, kind >= 0 && kind <= 5, "Invalid span kind: %v", span["kind"])
+				assert.Regexp(t, otlpUint64, span["startTimeUnixNano"], "Times must be encoded as decimal strings")
+				assert.Regexp(t, otlpUint64, span["endTimeUnixNano"], "Times must be encoded as decimal strings")
+				assertOTLPAttributes(t, span["attributes"])
+				if status, ok := span["status"]; ok {
+					code, _ := assertOTLP(t, "status", status)["code"].(float64)
+					assert.True(t, code >= 0 && code <= 2, "Invalid status code: %v", code)
+				}
+				spans = append(spans, span)
+			}
+		}
+	}
+	return spans
+}
+
+func TestRemoteSpan(t *testing.T) {
+	defer func(t *Tracer) { tracer = t }(tracer)
+	tracer = newTracer(&recordingExporter{}, 100)
+	defer tracer.Close()
+
+	s := remoteSpan("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	assert.NotNil(t, s)
+	assert.True(t, s.sampled)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", s.Traceparent())
+
+	child := startSpan("child", spanInternal, s)
+	assert.Equal(t, s.trace, child.trace, "Children must continue the trace")
+	assert.Equal(t, s.id, child.parent)
+	assert.NotEqual(t, s.id, child.id)
+
+	for _, header := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01",
+	} {
+		assert.Nil(t, remoteSpan(header), "Invalid traceparent must be ignored: %s", header)
+	}
+}
+
+func TestTracer_Export(t *testing.T) {
+	rec := &recordingExporter{}
+	defer func(t *Tracer) { tracer = t }(tracer)
+	tracer = newTracer(rec, 100)
+
+	parent := startSpan("parent", spanServer, nil)
+	startSpan("child", spanInternal, parent).End(errors.New("boom"))
+	parent.End(nil)
+	parent.End(nil)
+	startSpan("unsampled", spanInternal, remoteSpan("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")).End(nil)
+	assert.Nil(t, tracer.Close())
+
+	spans := rec.byName(parent.trace)
+	assert.Len(t, rec.spans, 2, "Spans must be exported once and only when sampled")
+	assert.Equal(t, errors.New("boom"), spans["child"].err)
+	assert.Equal(t, parent.id, spans["child"].parent)
+}
+
+func TestTracer_Off(t *testing.T) {
+	defer func(t *Tracer) { tracer = t }(tracer)
+	tracer = &Tracer{}
+
+	remote := remoteSpan("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	assert.Nil(t, startSpan("parent", spanServer, nil), "Spans must not be started while tracing is off")
+	assert.Nil(t, startSpan("child", spanInternal, remote))
+	assert.Equal(t, "", startSpan("parent", spanServer, nil).Traceparent())
+}
+
+func TestTracer_NoSample(t *testing.T) {
+	rec := &recordingExporter{}
+	defer func(t *Tracer) { tracer = t }(tracer)
+	tracer = newTracer(rec, 0)
+
+	for i := 0; i < 100; i++ {
+		s := startSpan("parent", spanServer, nil)
+		assert.False(t, s.sampled, "No trace must be sampled at 0 percent")
+		s.End(nil)
+	}
+	startSpan("remote", spanServer, remoteSpan("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")).End(nil)
+	assert.Nil(t, tracer.Close())
+	assert.Len(t, rec.spans, 1, "Only the traces sampled by the caller must be exported")
+}
+
+func TestConfigTracing_SamplePercent(t *testing.T) {
+	defer func(t *Tracer) { tracer = t }(tracer)
+	zero := 0
+	for _, c := range []struct {
+		percent *int
+		env     string
+		sample  int
+	}{
+		{nil, "", 100},
+		{&zero, "", 0},
+		{nil, "25", 25},
+		{&zero, "0", 0},
+	} {
+		os.Setenv("GATEWAY_TRACING_SAMPLE_PERCENT", c.env)
+		configTracing(&TracingConfig{Exporter: "otlp", SamplePercent: c.percent})
+		assert.Equal(t, c.sample, tracer.sample, "Unexpected sample percent of %v and %q", c.percent, c.env)
+		assert.Nil(t, tracer.Close())
+	}
+	os.Unsetenv("GATEWAY_TRACING_SAMPLE_PERCENT")
+
+	configTracing(&TracingConfig{})
+	assert.False(t, tracer.enabled(), "Tracing must be off by default")
+	hundred := 101
+	assert.Panics(t, func() { configTracing(&TracingConfig{SamplePercent: &hundred}) })
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var body map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		b, _ := ioutil.ReadAll(r.Body)
+		assert.Nil(t, json.Unmarshal(b, &body))
+	}))
+	defer ts.Close()
+	defer func(t *Tracer) { tracer = t }(tracer)
+	tracer = newTracer(&recordingExporter{}, 100)
+	defer tracer.Close()
+
+	s := startSpan("gateway.publish", spanProducer, startSpan("gateway.receive", spanServer, nil))
+	s.SetAttr("messaging.message.id", "m1")
+	s.SetAttr("gateway.conn", int64(3))
+	s.End(errors.New("boom"))
+	assert.Nil(t, NewOTLPExporter(ts.URL+"/", "gw").Export([]*Span{s}))
+
+	spans := assertOTLPRequest(t, body)
+	if !assert.Len(t, spans, 1) {
+		return
+	}
+	span := spans[0]
+	assert.Equal(t, "gateway.publish", span["name"])
+	assert.Equal(t, float64(spanProducer), span["kind"])
+	assert.Equal(t, s.Traceparent()[3:35], span["traceId"])
+	assert.Len(t, span["parentSpanId"], 16)
+	assert.Equal(t, map[string]interface{}{"code": float64(2), "message": "boom"}, span["status"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"key": "messaging.message.id", "value": map[string]interface{}{"stringValue": "m1"}},
+		map[string]interface{}{"key": "gateway.conn", "value": map[string]interface{}{"intValue": "3"}},
+	}, span["attributes"])
+
+	ts.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+	assert.NotNil(t, NewOTLPExporter(ts.URL, "gw").Export([]*Span{s}), "Rejected exports must fail")
+}
+
+func TestHandler_Tracing(t *testing.T) {
+	rec := &recordingExporter{}
+	defer func(t *Tracer) { tracer = t }(tracer)
+	tracer = newTracer(rec, 100)
+	out := &chanPublisher{out: make(chan *Message, 1)}
+	pub = out
+	ts, _ := newTestServer(t)
+	defer ts.Close()
+
+	ws := dialTestServer(t, ts, "/")
+	defer ws.Close()
+	// the second message is only received once the spans
+	// of the first one ended
+	var msgs []*Message
+	for _, body := range []string{"one", "two"} {
+		assert.Nil(t, websocket.Message.Send(ws, body))
+		select {
+		case msg := <-out.out:
+			msg.Delivered(nil)
+			msgs = append(msgs, msg)
+		case <-time.After(5 * time.Second):
+			t.Fatal("message was not published")
+		}
+	}
+	assert.Nil(t, tracer.Close())
+
+	msg := msgs[0]
+	spans := rec.byName(remoteSpan(msg.Trace).trace)
+	receive, transform, publish := spans["gateway.receive"], spans["gateway.transform"], spans["gateway.publish"]
+	if assert.NotNil(t, receive) && assert.NotNil(t, transform) && assert.NotNil(t, publish) {
+		assert.Equal(t, receive.id, transform.parent)
+		assert.Equal(t, receive.id, publish.parent)
+		assert.Equal(t, publish.Traceparent(), msg.Trace, "Messages must carry the context of their publish span")
+		assert.True(t, strings.HasPrefix(msg.Trace, "00-"))
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -59,6 +60,21 @@ func GetEnvVarAsInt(k string, d int) int {
 	return ParseInt(s, d)
 }
 
+// GetEnvVarAsInt64 wrapper for env variable as int64, for the values
+// which do not fit the 16 bits GetEnvVarAsInt allows
+func GetEnvVarAsInt64(k string, d int64) int64 {
+	s := GetEnvVarAsString(k, "")
+	if len(s) < 1 {
+		return d
+	}
+	v, err := strconv.ParseInt(s, 0, 64)
+	if err != nil {
+		log.Fatalf("unable to parse int from %s: %v", s, err)
+		return d
+	}
+	return v
+}
+
 // SetWithEnvVar sets variable to the string value of the env variable envVariable
 // if it is set. If no env variable by the name envVariable is found, variable
 // is not changed.
@@ -83,15 +99,15 @@ func GetEnvVarAsBool(k string, d bool) bool {
 	return v
 }
 
-// Trace outputs self to object as string
+// Trace outputs self to object as string at debug level
 func Trace(str string, o interface{}) {
-	if args.Trace {
+	if logger.Enabled(levelDebug) {
 		objStr, err := ToString(o)
 		if err != nil {
-			log.Printf("unable to marshal: %v", err.Error())
+			logger.Errorf("unable to marshal: %v", err.Error())
 			return
 		}
-		log.Printf("%s: %s", str, fmt.Sprintln(string(objStr)))
+		logger.Debugf("%s: %s", str, strings.TrimSpace(objStr))
 	}
 }
 
@@ -0,0 +1,128 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+const (
+	willHeader          = "X-Gateway-Will"
+	willParam           = "will"
+	willFrameType       = "will"
+	defaultWillMaxBytes = 4096
+)
+
+var (
+	errInvalidWillFrame = errors.New("invalid will, expected {\"type\": \"will\", \"body\": ...}")
+	errWillTooLarge     = errors.New("will is too large")
+
+	// wills takes the last wills of the clients which disconnected
+	// abruptly to willPub, nil when last wills are disabled
+	wills   chan *Message
+	willPub Publisher
+)
+
+// willFrame is the control frame registering the last will of the client
+type willFrame struct {
+	Type string          `json:"type"`
+	Body json.RawMessage `json:"body"`
+}
+
+// wantsWillFrame checks whether the client announced its last will
+// as the first frame it sends
+func wantsWillFrame(req *http.Request) bool {
+	if req == nil {
+		return false
+	}
+	will, _ := strconv.ParseBool(req.URL.Query().Get(willParam))
+	return will
+}
+
+// parseWillFrame extracts the last will from the control frame, a body
+// holding a JSON string is unquoted while anything else is kept as is
+func parseWillFrame(frame string) (string, error) {
+	var f willFrame
+	if err := json.Unmarshal([]byte(frame), &f); err != nil || f.Type != willFrameType || len(f.Body) == 0 {
+		return "", errInvalidWillFrame
+	}
+	var body string
+	if err := json.Unmarshal(f.Body, &body); err != nil {
+		body = string(f.Body)
+	}
+	return body, nil
+}
+
+// startWills starts the publisher of the last wills, they are published
+// with the backend of the gateway to the will topic
+func startWills(clientID string, args *PubConfig) {
+	willArgs := *args
+	willArgs.Topic = args.Will.Topic
+	willArgs.File.Name = args.Will.Topic
+	willPub = newPublisher(args.Backend)
+	willPub.Config(clientID, &willArgs)
+	wills = make(chan *Message, channelBufSize)
+	go willPub.Start(wills)
+}
+
+// setWill registers the last will of the client
+func (c *handler) setWill(will string) error {
+	if len(will) > args.Pub.Will.MaxBytes {
+		return errWillTooLarge
+	}
+	c.will = will
+	return nil
+}
+
+// registerWill registers the last will of the control frame, clients
+// asking for acks are told whether it was accepted while the others are
+// disconnected when it is rejected
+func (c *handler) registerWill(frame string) {
+	will, err := parseWillFrame(frame)
+	if err == nil {
+		err = c.setWill(will)
+	}
+	if err != nil {
+		c.log.Warnf("last will rejected: %v", err)
+	}
+	if c.acks {
+		var reply interface{} = newAckReply(willFrameType, err)
+		c.write(&reply)
+	} else if err != nil {
+		c.disconnect(closePolicyViolation, disconnectGrace)
+	}
+}
+
+// publishWill publishes the last will of the client, if it has one
+func (c *handler) publishWill() {
+	if wills == nil || len(c.will) == 0 {
+		return
+	}
+	m := NewMessage(c.will)
+	m.Device = c.device
+	m.Auth = c.auth
+	log := c.log.Message(m)
+	m.delivered = func(err error) {
+		if err != nil {
+			log.Errorf("Error on last will publish: %v", err)
+		}
+	}
+	log.Infof("publishing last will")
+	wills <- m
+}
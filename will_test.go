@@ -0,0 +1,221 @@
+/**
+ * Copyright (c) 2015 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"code.google.com/p/go.net/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWillFrame(t *testing.T) {
+	will, err := parseWillFrame(`{"type": "will", "body": "offline"}`)
+	assert.Nil(t, err)
+	assert.Equal(t, "offline", will, "String wills must be unquoted")
+
+	will, err = parseWillFrame(`{"type": "will", "body": {"status": "lost"}}`)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"status": "lost"}`, will, "JSON wills must be kept as is")
+
+	for _, frame := range []string{`offline`, `{"type": "will"}`, `{"id": "1", "body": "x"}`} {
+		_, err = parseWillFrame(frame)
+		assert.Equal(t, errInvalidWillFrame, err, "Invalid will frame must be rejected: %s", frame)
+	}
+}
+
+// dialWithWill connects to the test server with the last will header if
+// any, the TCP connection is returned as well to cut it off
+func dialWithWill(t *testing.T, ts string, path, will string) (*websocket.Conn, *net.TCPConn) {
+	config, err := websocket.NewConfig(ts+path, "http://localhost/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if will != "" {
+		config.Header = http.Header{willHeader: {will}}
+	}
+	conn, err := net.Dial("tcp", config.Location.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws, err := websocket.NewClient(config, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ws, conn.(*net.TCPConn)
+}
+
+// serveDone serves a broker telling when its clients are done, that is
+// once their will is published or discarded
+func serveDone(t *testing.T) (*httptest.Server, *broker, <-chan *handler) {
+	ts, b := newTestServer(t)
+	done := make(chan *handler, 10)
+	ts.Config.Handler = websocket.Handler(func(ws *websocket.Conn) {
+		h := newClient(ws, b)
+		b.add(h)
+		h.listen()
+		done <- h
+	})
+	return ts, b, done
+}
+
+// waitDone waits for a client of serveDone to be done
+func waitDone(t *testing.T, done <-chan *handler) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("client was not disconnected")
+	}
+}
+
+// reset cuts the TCP connection off without closing the websocket
+func reset(conn *net.TCPConn) {
+	conn.SetLinger(0)
+	conn.Close()
+}
+
+func TestHandler_Will(t *testing.T) {
+	pub = &ackPublisher{}
+	defer func(ch chan *Message) { wills = ch }(wills)
+	wills = make(chan *Message, 10)
+	ts, b, done := serveDone(t)
+	defer ts.Close()
+	url := "ws" + ts.URL[len("http"):]
+
+	// wills are discarded on a clean close
+	ws, _ := dialWithWill(t, url, "/", "gone")
+	waitClients(t, b, 1)
+	ws.Close()
+	waitDone(t, done)
+
+	// and on connections the gateway cuts off
+	ws, _ = dialWithWill(t, url, "/", "kicked")
+	defer ws.Close()
+	waitClients(t, b, 1)[0].disconnect(closePolicyViolation, 10*time.Millisecond)
+	waitDone(t, done)
+
+	// but published when the connection ends abruptly
+	_, conn := dialWithWill(t, url, "/", "lost")
+	waitClients(t, b, 1)
+	reset(conn)
+	select {
+	case m := <-wills:
+		assert.Equal(t, "lost", m.Body)
+	case <-time.After(5 * time.Second):
+		t.Fatal("last will was not published")
+	}
+	waitDone(t, done)
+	assert.Len(t, wills, 0, "Wills of clean closes must be discarded")
+}
+
+func TestHandler_WillAdminDisconnect(t *testing.T) {
+	pub = &ackPublisher{}
+	defer func(ch chan *Message) { wills = ch }(wills)
+	wills = make(chan *Message, 10)
+	ts, b, done := serveDone(t)
+	defer ts.Close()
+	h := newAdminHandler(b, "secret")
+
+	defer func(d time.Duration) { disconnectGrace = d }(disconnectGrace)
+	disconnectGrace = 50 * time.Millisecond
+
+	// the client never answers the close frame and is cut off
+	ws, _ := dialWithWill(t, "ws"+ts.URL[len("http"):], "/", "lost")
+	defer ws.Close()
+	clients := waitClients(t, b, 1)
+	w := adminRequest(h, "DELETE", "/admin/connections/"+jsonString(clients[0].id), "secret")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	waitDone(t, done)
+	assert.Len(t, wills, 0, "Wills of connections closed by an admin must be discarded")
+}
+
+func TestHandler_WillShutdown(t *testing.T) {
+	pub = &ackPublisher{}
+	defer func(ch chan *Message) { wills = ch }(wills)
+	wills = make(chan *Message, 10)
+	ts, b, done := serveDone(t)
+	defer ts.Close()
+
+	// the client never answers the close frame and is cut off
+	ws, _ := dialWithWill(t, "ws"+ts.URL[len("http"):], "/", "lost")
+	defer ws.Close()
+	waitClients(t, b, 1)
+	shutdown(&http.Server{}, b, 200*time.Millisecond)
+	waitDone(t, done)
+	assert.Len(t, wills, 0, "Wills of connections closed on shutdown must be discarded")
+}
+
+func TestHandler_WillFrame(t *testing.T) {
+	pub = &ackPublisher{}
+	defer func(ch chan *Message) { wills = ch }(wills)
+	wills = make(chan *Message, 10)
+	ts, b := newTestServer(t)
+	defer ts.Close()
+
+	ws, conn := dialWithWill(t, "ws"+ts.URL[len("http"):], "/?will=true&ack=true", "")
+	defer ws.Close()
+	ws.SetDeadline(time.Now().Add(5 * time.Second))
+	assert.Nil(t, websocket.Message.Send(ws, `{"type": "will", "body": {"status": "lost"}}`))
+	var reply ackReply
+	assert.Nil(t, websocket.JSON.Receive(ws, &reply))
+	assert.Equal(t, ackReply{Type: "ack", ID: willFrameType}, reply)
+
+	// the following frames are messages
+	assert.Nil(t, websocket.Message.Send(ws, `{"id": "1", "body": "x"}`))
+	assert.Nil(t, websocket.JSON.Receive(ws, &reply))
+	assert.Equal(t, ackReply{Type: "ack", ID: "1"}, reply)
+
+	c := waitClients(t, b, 1)[0]
+	reset(conn)
+	m := <-wills
+	assert.Equal(t, `{"status": "lost"}`, m.Body)
+	assert.Equal(t, c.device, m.Device)
+}
+
+func TestHandler_WillFrameRejected(t *testing.T) {
+	pub = &ackPublisher{}
+	defer func(ch chan *Message) { wills = ch }(wills)
+	wills = make(chan *Message, 10)
+	ts, _ := newTestServer(t)
+	defer ts.Close()
+
+	// clients using acks are nacked and go on
+	ws := dialTestServer(t, ts, "/?will=true&ack=true")
+	defer ws.Close()
+	ws.SetDeadline(time.Now().Add(5 * time.Second))
+	assert.Nil(t, websocket.Message.Send(ws, `{"id": "1", "body": "x"}`))
+	var reply ackReply
+	assert.Nil(t, websocket.JSON.Receive(ws, &reply))
+	assert.Equal(t, ackReply{Type: "nack", ID: willFrameType, Reason: errInvalidWillFrame.Error()}, reply)
+	assert.Nil(t, websocket.Message.Send(ws, `{"id": "2", "body": "x"}`))
+	reply = ackReply{}
+	assert.Nil(t, websocket.JSON.Receive(ws, &reply))
+	assert.Equal(t, ackReply{Type: "ack", ID: "2"}, reply)
+
+	// the others are disconnected
+	ws2 := dialTestServer(t, ts, "/?will=true")
+	defer ws2.Close()
+	ws2.SetDeadline(time.Now().Add(5 * time.Second))
+	assert.Nil(t, websocket.Message.Send(ws2, `{"id": "1", "body": "x"}`))
+	var frame string
+	assert.Equal(t, io.EOF, websocket.Message.Receive(ws2, &frame), "Invalid wills must close the connection")
+}